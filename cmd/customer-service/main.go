@@ -3,135 +3,270 @@ package main
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 
-	_ "github.com/lib/pq"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"github.com/jackc/pgx/v5/pgxpool"
 
+	"testovoe/internal/audit"
+	"testovoe/internal/config"
+	"testovoe/internal/customer/gateway"
 	"testovoe/internal/customer/grpc"
 	"testovoe/internal/customer/repo"
 	"testovoe/internal/customer/service"
+	"testovoe/internal/database"
+	"testovoe/internal/health"
+	"testovoe/internal/logging"
+	"testovoe/internal/metrics"
+	"testovoe/internal/migrate"
+	"testovoe/internal/ratelimit"
+	"testovoe/internal/telemetry"
+	"testovoe/migrations"
 )
 
-func initTracer() func() {
+// configPollInterval is how often the config file is checked for changes.
+const configPollInterval = 5 * time.Second
+
+// store is what main needs from the customer repositories.
+type store interface {
+	service.Repository
+	health.Pinger
+	audit.Store
+}
+
+// openStore returns the repository selected by cfg.Storage and a function
+// releasing it.
+func openStore(cfg *config.Config) (store, func()) {
 	ctx := context.Background()
 
-	otelEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if otelEndpoint == "" {
-		otelEndpoint = "localhost:4317"
-	} else {
-		otelEndpoint = strings.TrimPrefix(otelEndpoint, "http://")
-		otelEndpoint = strings.TrimPrefix(otelEndpoint, "https://")
+	switch cfg.Storage {
+	case config.StorageMemory:
+		slog.Info("Using in-memory storage; data is lost on restart")
+		return repo.NewMemoryRepository(), func() {}
+
+	case config.StorageSQLite:
+		db, err := database.OpenSQLite(ctx, cfg.SQLite.Path)
+		if err != nil {
+			logging.Fatal("failed to open database", "error", err)
+		}
+
+		slog.Info("Using SQLite database", "path", cfg.SQLite.Path)
+
+		// A local file has no one else to migrate it, so it is always
+		// brought up to date.
+		runner, err := newMigrator(cfg, nil, db)
+		if err != nil {
+			logging.Fatal("failed to load migrations", "error", err)
+		}
+		applyMigrations(runner)
+
+		return repo.NewSQLiteRepository(db), func() { db.Close() }
 	}
 
-	exporter, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithEndpoint(otelEndpoint),
-		otlptracegrpc.WithInsecure(),
-	)
+	db, err := database.Open(ctx, cfg.DB)
 	if err != nil {
-		log.Fatalf("failed to create OTLP exporter: %v", err)
+		logging.Fatal("failed to connect to database", "error", err)
 	}
 
-	serviceName := os.Getenv("OTEL_SERVICE_NAME")
-	if serviceName == "" {
-		serviceName = "customer-service"
-	}
+	slog.Info("Connected to database")
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String(serviceName),
-		),
-	)
-	if err != nil {
-		log.Fatalf("failed to create resource: %v", err)
+	if cfg.DB.AutoMigrate {
+		runner, err := newMigrator(cfg, db, nil)
+		if err != nil {
+			logging.Fatal("failed to load migrations", "error", err)
+		}
+		applyMigrations(runner)
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-	)
+	if err := database.RegisterPoolMetrics(db, cfg.Telemetry.ServiceName); err != nil {
+		slog.Error("failed to register pool metrics", "error", err)
+	}
 
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
+	return repo.NewRepository(db), db.Close
+}
 
-	return func() {
-		if err := tp.Shutdown(ctx); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
-		}
+// newMigrator returns the migration runner for the configured storage;
+// exactly one of pool and sqlDB is set.
+func newMigrator(cfg *config.Config, pool *pgxpool.Pool, sqlDB *sql.DB) (*migrate.Runner, error) {
+	fsys, err := migrations.Customer(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if sqlDB != nil {
+		return migrate.NewSQLite(sqlDB, config.CustomerService, fsys)
 	}
+	return migrate.New(pool, config.CustomerService, fsys)
 }
 
-func main() {
-	shutdown := initTracer()
-	defer shutdown()
+func applyMigrations(runner *migrate.Runner) {
+	defer runner.Close()
 
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost"
+	n, err := runner.Up(context.Background())
+	if err != nil {
+		logging.Fatal("failed to apply migrations", "error", err)
 	}
-	dbPort := os.Getenv("DB_PORT")
-	if dbPort == "" {
-		dbPort = "5432"
+	slog.Info("Applied migrations", "count", n)
+}
+
+// runMigrate implements "customer-service migrate [flags] <command>".
+func runMigrate(args []string) {
+	cfg, err := config.Load(config.CustomerService, args)
+	if err != nil {
+		logging.Fatal("failed to load config", "error", err)
 	}
-	dbUser := os.Getenv("DB_USER")
-	if dbUser == "" {
-		dbUser = "postgres"
+
+	ctx := context.Background()
+
+	var (
+		runner  *migrate.Runner
+		closeDB func()
+	)
+	switch cfg.Storage {
+	case config.StoragePostgres:
+		var pool *pgxpool.Pool
+		if pool, err = database.Open(ctx, cfg.DB); err != nil {
+			logging.Fatal("failed to connect to database", "error", err)
+		}
+		runner, err = newMigrator(cfg, pool, nil)
+		closeDB = pool.Close
+	case config.StorageSQLite:
+		var db *sql.DB
+		if db, err = database.OpenSQLite(ctx, cfg.SQLite.Path); err != nil {
+			logging.Fatal("failed to open database", "error", err)
+		}
+		runner, err = newMigrator(cfg, nil, db)
+		closeDB = func() { db.Close() }
+	default:
+		logging.Fatal("migrate does not support this storage", "storage", cfg.Storage)
 	}
-	dbPassword := os.Getenv("DB_PASSWORD")
-	if dbPassword == "" {
-		dbPassword = "postgres"
+
+	if err != nil {
+		closeDB()
+		logging.Fatal("failed to load migrations", "error", err)
 	}
-	dbName := os.Getenv("DB_NAME")
-	if dbName == "" {
-		dbName = "testovoe"
+	defer closeDB()
+	defer runner.Close()
+
+	if err := runner.Command(ctx, cfg.Args(), os.Stdout); err != nil {
+		runner.Close()
+		closeDB()
+		logging.Fatal("migrate", "error", err)
 	}
+}
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+func main() {
+	logging.Setup()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
 
-	db, err := sql.Open("postgres", dsn)
+	cfg, err := config.Load(config.CustomerService, os.Args[1:])
 	if err != nil {
-		log.Fatalf("failed to open database: %v", err)
+		logging.Fatal("failed to load config", "error", err)
 	}
-	defer db.Close()
 
-	if err := db.Ping(); err != nil {
-		log.Fatalf("failed to ping database: %v", err)
+	providers, err := telemetry.Setup(context.Background(), cfg)
+	if err != nil {
+		logging.Fatal("failed to set up telemetry", "error", err)
 	}
 
-	log.Println("Connected to database")
+	slog.Info("Effective configuration", "config", cfg.String())
 
-	repo := repo.NewRepository(db)
-	svc := service.NewService(repo)
+	limiter := ratelimit.New()
+	reloader := config.NewReloader(cfg, os.Args[1:])
+	reloader.Subscribe(func(s *config.Snapshot) {
+		if err := logging.SetLevel(s.Runtime.LogLevel); err != nil {
+			slog.Error("Invalid runtime config", "version", s.Version, "error", err)
+		}
+		limiter.Update(s.Runtime.RateLimit.RPS, s.Runtime.RateLimit.Burst)
+	})
+
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	go reloader.Run(reloadCtx, configPollInterval)
+
+	store, closeStore := openStore(cfg)
+
+	svc := service.NewService(store)
 
-	grpcPort := os.Getenv("GRPC_PORT")
-	if grpcPort == "" {
-		grpcPort = "9090"
+	grpcOpts := grpc.Options{
+		Port:           cfg.GRPC.Port,
+		Reflection:     cfg.GRPC.Reflection,
+		HealthInterval: cfg.GRPC.HealthInterval,
+		DrainTimeout:   cfg.GRPC.DrainTimeout,
+		RateLimiter:    limiter,
 	}
 
+	grpcSrv, err := grpc.StartGRPCServer(context.Background(), grpcOpts, svc, store)
+	if err != nil {
+		logging.Fatal("failed to start gRPC server", "error", err)
+	}
+
+	gatewaySrv, err := gateway.NewGatewayServer(cfg.HTTP.Port, grpc.NewServer(svc, limiter))
+	if err != nil {
+		logging.Fatal("failed to create REST gateway", "error", err)
+	}
+
+	gatewayErr := make(chan error, 1)
+	go func() {
+		slog.Info("Customer REST gateway listening", "port", cfg.HTTP.Port)
+		if err := gatewaySrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			gatewayErr <- err
+		}
+	}()
+
+	adminSrv := metrics.NewAdminServer(cfg.Admin.Port, providers.MetricsHandler, map[string]http.Handler{
+		"GET /admin/config":       reloader,
+		"GET /admin/audit":        audit.ListHandler(store),
+		"GET /admin/audit/verify": audit.VerifyHandler(store),
+	})
+	adminErr := make(chan error, 1)
 	go func() {
-		if err := grpc.StartGRPCServer(grpcPort, svc); err != nil {
-			log.Fatalf("failed to start gRPC server: %v", err)
+		slog.Info("Admin server listening", "port", cfg.Admin.Port)
+		if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			adminErr <- err
 		}
 	}()
 
 	// Graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
 
-	log.Println("Shutting down...")
+	select {
+	case <-sigChan:
+	case err := <-grpcSrv.Err():
+		slog.Error("gRPC server stopped", "error", err)
+	case err := <-gatewayErr:
+		slog.Error("REST gateway stopped", "error", err)
+	case err := <-adminErr:
+		slog.Error("Admin server stopped", "error", err)
+	}
+
+	slog.Info("Shutting down...")
+
+	// Order matters: report NOT_SERVING and drain RPCs while the database is
+	// still open, then flush spans, metrics and logs recorded by those RPCs,
+	// and only then close the database.
+	grpcSrv.GracefulStop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.GRPC.DrainTimeout)
+	defer cancel()
+	if err := gatewaySrv.Shutdown(ctx); err != nil {
+		slog.Error("Error shutting down REST gateway", "error", err)
+	}
+	if err := adminSrv.Shutdown(ctx); err != nil {
+		slog.Error("Error shutting down admin server", "error", err)
+	}
+
+	if err := providers.Shutdown(); err != nil {
+		slog.Error("Error shutting down telemetry", "error", err)
+	}
+
+	closeStore()
 }
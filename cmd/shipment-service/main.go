@@ -3,169 +3,369 @@ package main
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 
+	"testovoe/internal/audit"
+	"testovoe/internal/auth"
+	"testovoe/internal/config"
+	"testovoe/internal/database"
+	"testovoe/internal/logging"
+	"testovoe/internal/metrics"
+	"testovoe/internal/migrate"
+	"testovoe/internal/ratelimit"
+	"testovoe/internal/reqctx"
+	"testovoe/internal/shipment/customersync"
 	"testovoe/internal/shipment/grpc"
+	"testovoe/internal/shipment/grpcserver"
 	httphandler "testovoe/internal/shipment/http"
 	"testovoe/internal/shipment/repo"
 	"testovoe/internal/shipment/service"
+	"testovoe/internal/telemetry"
+	"testovoe/migrations"
 )
 
-func initTracer() func() {
+// configPollInterval is how often the config file is checked for changes.
+const configPollInterval = 5 * time.Second
+
+// adminShutdownTimeout bounds the shutdown of the admin server after the
+// API servers have drained.
+const adminShutdownTimeout = 2 * time.Second
+
+// store is what main needs from the shipment repositories.
+type store interface {
+	service.Repository
+	customersync.Store
+	audit.Store
+	Ping(ctx context.Context) error
+}
+
+// openStore returns the repository selected by cfg.Storage and a function
+// releasing it.
+func openStore(cfg *config.Config) (store, func()) {
 	ctx := context.Background()
 
-	otelEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if otelEndpoint == "" {
-		otelEndpoint = "localhost:4317"
-	} else {
-		otelEndpoint = strings.TrimPrefix(otelEndpoint, "http://")
-		otelEndpoint = strings.TrimPrefix(otelEndpoint, "https://")
+	switch cfg.Storage {
+	case config.StorageMemory:
+		slog.Info("Using in-memory storage; data is lost on restart")
+		return repo.NewMemoryRepository(), func() {}
+
+	case config.StorageSQLite:
+		db, err := database.OpenSQLite(ctx, cfg.SQLite.Path)
+		if err != nil {
+			logging.Fatal("failed to open database", "error", err)
+		}
+
+		slog.Info("Using SQLite database", "path", cfg.SQLite.Path)
+
+		// A local file has no one else to migrate it, so it is always
+		// brought up to date.
+		runner, err := newMigrator(cfg, nil, db)
+		if err != nil {
+			logging.Fatal("failed to load migrations", "error", err)
+		}
+		applyMigrations(runner)
+
+		return repo.NewSQLiteRepository(db), func() { db.Close() }
 	}
 
-	exporter, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithEndpoint(otelEndpoint),
-		otlptracegrpc.WithInsecure(),
-	)
+	db, err := database.Open(ctx, cfg.DB)
 	if err != nil {
-		log.Fatalf("failed to create OTLP exporter: %v", err)
+		logging.Fatal("failed to connect to database", "error", err)
 	}
 
-	serviceName := os.Getenv("OTEL_SERVICE_NAME")
-	if serviceName == "" {
-		serviceName = "shipment-service"
-	}
+	slog.Info("Connected to database")
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String(serviceName),
-		),
-	)
-	if err != nil {
-		log.Fatalf("failed to create resource: %v", err)
+	if cfg.DB.AutoMigrate {
+		runner, err := newMigrator(cfg, db, nil)
+		if err != nil {
+			logging.Fatal("failed to load migrations", "error", err)
+		}
+		applyMigrations(runner)
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-	)
+	if err := database.RegisterPoolMetrics(db, cfg.Telemetry.ServiceName); err != nil {
+		slog.Error("failed to register pool metrics", "error", err)
+	}
 
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
+	return repo.NewRepository(db), db.Close
+}
 
-	return func() {
-		if err := tp.Shutdown(ctx); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
-		}
+// newMigrator returns the migration runner for the configured storage;
+// exactly one of pool and sqlDB is set.
+func newMigrator(cfg *config.Config, pool *pgxpool.Pool, sqlDB *sql.DB) (*migrate.Runner, error) {
+	fsys, err := migrations.Shipment(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if sqlDB != nil {
+		return migrate.NewSQLite(sqlDB, config.ShipmentService, fsys)
 	}
+	return migrate.New(pool, config.ShipmentService, fsys)
 }
 
-func main() {
-	shutdown := initTracer()
-	defer shutdown()
+func applyMigrations(runner *migrate.Runner) {
+	defer runner.Close()
 
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost"
+	n, err := runner.Up(context.Background())
+	if err != nil {
+		logging.Fatal("failed to apply migrations", "error", err)
 	}
-	dbPort := os.Getenv("DB_PORT")
-	if dbPort == "" {
-		dbPort = "5432"
+	slog.Info("Applied migrations", "count", n)
+}
+
+// runMigrate implements "shipment-service migrate [flags] <command>".
+func runMigrate(args []string) {
+	cfg, err := config.Load(config.ShipmentService, args)
+	if err != nil {
+		logging.Fatal("failed to load config", "error", err)
 	}
-	dbUser := os.Getenv("DB_USER")
-	if dbUser == "" {
-		dbUser = "postgres"
+
+	ctx := context.Background()
+
+	var (
+		runner  *migrate.Runner
+		closeDB func()
+	)
+	switch cfg.Storage {
+	case config.StoragePostgres:
+		var pool *pgxpool.Pool
+		if pool, err = database.Open(ctx, cfg.DB); err != nil {
+			logging.Fatal("failed to connect to database", "error", err)
+		}
+		runner, err = newMigrator(cfg, pool, nil)
+		closeDB = pool.Close
+	case config.StorageSQLite:
+		var db *sql.DB
+		if db, err = database.OpenSQLite(ctx, cfg.SQLite.Path); err != nil {
+			logging.Fatal("failed to open database", "error", err)
+		}
+		runner, err = newMigrator(cfg, nil, db)
+		closeDB = func() { db.Close() }
+	default:
+		logging.Fatal("migrate does not support this storage", "storage", cfg.Storage)
 	}
-	dbPassword := os.Getenv("DB_PASSWORD")
-	if dbPassword == "" {
-		dbPassword = "postgres"
+
+	if err != nil {
+		closeDB()
+		logging.Fatal("failed to load migrations", "error", err)
 	}
-	dbName := os.Getenv("DB_NAME")
-	if dbName == "" {
-		dbName = "testovoe"
+	defer closeDB()
+	defer runner.Close()
+
+	if err := runner.Command(ctx, cfg.Args(), os.Stdout); err != nil {
+		runner.Close()
+		closeDB()
+		logging.Fatal("migrate", "error", err)
 	}
+}
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+// newAuthenticator loads the JWKS file of cfg and keeps it reloaded until
+// ctx is done. It returns nil when authentication is disabled.
+func newAuthenticator(ctx context.Context, cfg config.AuthConfig) *auth.Authenticator {
+	if cfg.Disabled {
+		slog.Warn("JWT authentication is disabled by AUTH_DISABLED; the API is open to anyone who can reach it")
+		return nil
+	}
+	if cfg.JWKSFile == "" {
+		logging.Fatal("AUTH_JWKS_FILE is required; set AUTH_DISABLED=true to run without authentication")
+	}
 
-	db, err := sql.Open("postgres", dsn)
+	keys, err := auth.NewKeySet(cfg.JWKSFile)
 	if err != nil {
-		log.Fatalf("failed to open database: %v", err)
+		logging.Fatal("failed to load JWKS", "error", err)
 	}
-	defer db.Close()
+	go keys.Run(ctx, cfg.JWKSReloadInterval)
 
-	if err := db.Ping(); err != nil {
-		log.Fatalf("failed to ping database: %v", err)
-	}
+	slog.Info("JWT authentication enabled", "jwks_file", cfg.JWKSFile)
+	return auth.NewAuthenticator(keys, auth.Config{
+		Issuer:     cfg.Issuer,
+		Audience:   cfg.Audience,
+		ReadScope:  cfg.ReadScope,
+		WriteScope: cfg.WriteScope,
+	})
+}
 
-	log.Println("Connected to database")
+func main() {
+	logging.Setup()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
 
-	grpcEndpoint := os.Getenv("GRPC_ENVOY_ENDPOINT")
-	if grpcEndpoint == "" {
-		grpcEndpoint = "localhost:9090"
+	cfg, err := config.Load(config.ShipmentService, os.Args[1:])
+	if err != nil {
+		logging.Fatal("failed to load config", "error", err)
 	}
 
-	customerGrpc, err := grpc.NewClient(grpcEndpoint)
+	providers, err := telemetry.Setup(context.Background(), cfg)
 	if err != nil {
-		log.Fatalf("failed to create customer gRPC client: %v", err)
+		logging.Fatal("failed to set up telemetry", "error", err)
 	}
-	defer customerGrpc.Close()
+	// Deferred first so it runs last and the shutdown logs are exported.
+	defer func() {
+		if err := providers.Shutdown(); err != nil {
+			slog.Error("Error shutting down telemetry", "error", err)
+		}
+	}()
+
+	slog.Info("Effective configuration", "config", cfg.String())
+
+	store, closeStore := openStore(cfg)
+	defer closeStore()
+
+	// customerGrpc stays nil with the in-memory customer-service, which
+	// needs no retries, sync or health check.
+	var (
+		customers    service.CustomerClient
+		customerGrpc *grpc.Client
+	)
+	if cfg.Customer.Endpoint == config.CustomerEndpointMemory {
+		slog.Info("Using in-memory customer-service; customers are lost on restart")
+		customers = grpc.NewMemoryClient()
+	} else {
+		customerGrpc, err = grpc.NewClient(cfg.Customer.Endpoint)
+		if err != nil {
+			logging.Fatal("failed to create customer gRPC client", "error", err)
+		}
+		defer customerGrpc.Close()
+		customers = customerGrpc
+
+		slog.Info("Connected to customer service via Envoy", "endpoint", cfg.Customer.Endpoint)
+	}
+
+	limiter := ratelimit.New()
+	reloader := config.NewReloader(cfg, os.Args[1:])
+	reloader.Subscribe(func(s *config.Snapshot) {
+		if err := logging.SetLevel(s.Runtime.LogLevel); err != nil {
+			slog.Error("Invalid runtime config", "version", s.Version, "error", err)
+		}
+		limiter.Update(s.Runtime.RateLimit.RPS, s.Runtime.RateLimit.Burst)
+		if customerGrpc != nil {
+			customerGrpc.SetRetryPolicy(grpc.RetryPolicy{
+				MaxAttempts:    s.Runtime.Retry.MaxAttempts,
+				InitialBackoff: s.Runtime.Retry.InitialBackoff,
+				MaxBackoff:     s.Runtime.Retry.MaxBackoff,
+			})
+		}
+	})
 
-	log.Printf("Connected to customer service via Envoy at %s", grpcEndpoint)
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	go reloader.Run(reloadCtx, configPollInterval)
 
-	repo := repo.NewRepository(db)
-	svc := service.NewService(repo, customerGrpc)
+	authCtx, stopAuth := context.WithCancel(context.Background())
+	defer stopAuth()
+	authenticator := newAuthenticator(authCtx, cfg.Auth)
+
+	svc := service.NewService(store, customers)
 	handler := httphandler.NewHandler(svc)
 
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	if customerGrpc != nil && cfg.Customer.SyncInterval > 0 {
+		syncer := customersync.NewSyncer(store, customerGrpc, cfg.Customer.SyncInterval)
+		go syncer.Run(syncCtx)
+	}
+
 	router := mux.NewRouter()
 	router.Use(otelmux.Middleware("shipment-service"))
 
 	api := router.PathPrefix("/api/v1").Subrouter()
-	api.HandleFunc("/shipments", handler.CreateShipment).Methods("POST")
-	api.HandleFunc("/shipments/{id}", handler.GetShipment).Methods("GET")
+	api.Use(reqctx.Middleware, metrics.MuxMiddleware, ratelimit.Middleware(limiter))
+
+	if authenticator != nil {
+		api.Use(authenticator.Middleware)
+	}
+	handler.RegisterRoutes(api)
 
-	httpPort := os.Getenv("HTTP_PORT")
-	if httpPort == "" {
-		httpPort = "8080"
+	health := httphandler.NewHealthHandler()
+	health.AddCheck(cfg.Storage, store.Ping)
+	if customerGrpc != nil {
+		health.AddCheck("customer_grpc", customerGrpc.CheckConnection)
 	}
 
+	// Health endpoints sit outside the instrumented router so probes do not
+	// flood the tracing backend.
+	rootMux := http.NewServeMux()
+	rootMux.HandleFunc("GET /healthz", health.Liveness)
+	rootMux.HandleFunc("GET /readyz", health.Readiness)
+	rootMux.Handle("/", router)
+
 	srv := &http.Server{
-		Addr:    ":" + httpPort,
-		Handler: router,
+		Addr:    ":" + cfg.HTTP.Port,
+		Handler: rootMux,
 	}
 
 	go func() {
-		log.Printf("Shipment HTTP server listening on :%s", httpPort)
+		slog.Info("Shipment HTTP server listening", "port", cfg.HTTP.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("failed to start HTTP server: %v", err)
+			logging.Fatal("failed to start HTTP server", "error", err)
+		}
+	}()
+
+	// The admin port is not published by Envoy, so the internal endpoints
+	// stay off the API port.
+	adminSrv := metrics.NewAdminServer(cfg.Admin.Port, providers.MetricsHandler, map[string]http.Handler{
+		"GET /admin/config":       reloader,
+		"GET /admin/audit":        audit.ListHandler(store),
+		"GET /admin/audit/verify": audit.VerifyHandler(store),
+	})
+	go func() {
+		slog.Info("Admin server listening", "port", cfg.Admin.Port)
+		if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("failed to start admin server", "error", err)
 		}
 	}()
 
+	grpcSrv, err := grpcserver.StartGRPCServer(context.Background(), grpcserver.Options{
+		Port:           cfg.GRPC.Port,
+		HealthInterval: cfg.GRPC.HealthInterval,
+		RateLimiter:    limiter,
+		Authenticator:  authenticator,
+	}, svc, store)
+	if err != nil {
+		logging.Fatal("failed to start gRPC server", "error", err)
+	}
+
 	// Graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
 
-	log.Println("Shutting down...")
-	if err := srv.Shutdown(context.Background()); err != nil {
-		log.Printf("Error shutting down HTTP server: %v", err)
+	select {
+	case <-sigChan:
+	case err := <-grpcSrv.Err():
+		slog.Error("gRPC server stopped", "error", err)
+	}
+
+	slog.Info("Shutting down...")
+
+	stopSync()
+	health.SetDraining(true)
+	slog.Info("Readiness set to failing, waiting before shutdown", "delay", cfg.Shutdown.DrainDelay.String())
+	time.Sleep(cfg.Shutdown.DrainDelay)
+
+	// One deadline covers the HTTP and gRPC drain; the admin server only
+	// serves short internal requests and gets its own.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.GRPC.DrainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("Error shutting down HTTP server", "error", err)
+	}
+	grpcSrv.Shutdown(ctx)
+
+	adminCtx, adminCancel := context.WithTimeout(context.Background(), adminShutdownTimeout)
+	defer adminCancel()
+	if err := adminSrv.Shutdown(adminCtx); err != nil {
+		slog.Error("Error shutting down admin server", "error", err)
 	}
 }
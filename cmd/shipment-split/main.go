@@ -0,0 +1,192 @@
+// Command shipment-split moves shipment data out of the shared database
+// into the shipment-service database.
+//
+// It applies the shipment migrations to the target, copies every customer
+// into customer_refs and every shipment into shipments, then compares row
+// counts. Rows already in the target are left as they are, so the tool can
+// be re-run after an interruption. The source is never modified; drop the
+// old shipments table once shipment-service runs against the new database.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"testovoe/internal/config"
+	"testovoe/internal/logging"
+	"testovoe/internal/migrate"
+	"testovoe/migrations"
+)
+
+func main() {
+	logging.Setup()
+
+	source := flag.String("source", os.Getenv("SOURCE_DSN"), "shared database holding customers and shipments (env SOURCE_DSN)")
+	target := flag.String("target", os.Getenv("TARGET_DSN"), "shipment-service database (env TARGET_DSN)")
+	batch := flag.Int("batch", 1000, "rows copied per transaction")
+	flag.Parse()
+
+	if *source == "" || *target == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *batch <= 0 {
+		logging.Fatal("batch must be positive", "batch", *batch)
+	}
+
+	ctx := context.Background()
+
+	src, err := pgxpool.New(ctx, *source)
+	if err != nil {
+		logging.Fatal("failed to connect to source", "error", err)
+	}
+	defer src.Close()
+
+	dst, err := pgxpool.New(ctx, *target)
+	if err != nil {
+		logging.Fatal("failed to connect to target", "error", err)
+	}
+	defer dst.Close()
+
+	if err := run(ctx, src, dst, *batch); err != nil {
+		src.Close()
+		dst.Close()
+		logging.Fatal("split failed", "error", err)
+	}
+}
+
+func run(ctx context.Context, src, dst *pgxpool.Pool, batch int) error {
+	fsys, err := migrations.Shipment(config.StoragePostgres)
+	if err != nil {
+		return err
+	}
+	runner, err := migrate.New(dst, config.ShipmentService, fsys)
+	if err != nil {
+		return err
+	}
+	defer runner.Close()
+	n, err := runner.Up(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("Applied migrations to target", "count", n)
+
+	start := time.Now()
+
+	customers, err := copyTable(ctx, src, dst, batch,
+		`SELECT id, idn, created_at FROM customers WHERE id::text > $1 ORDER BY id::text LIMIT $2`,
+		`INSERT INTO customer_refs (id, idn, created_at) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING`,
+		3)
+	if err != nil {
+		return fmt.Errorf("failed to copy customers: %w", err)
+	}
+	slog.Info("Copied customers to customer_refs", "count", customers)
+
+	shipments, err := copyTable(ctx, src, dst, batch,
+		`SELECT id, route, price, status, customer_id, created_at FROM shipments WHERE id::text > $1 ORDER BY id::text LIMIT $2`,
+		`INSERT INTO shipments (id, route, price, status, customer_id, created_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (id) DO NOTHING`,
+		6)
+	if err != nil {
+		return fmt.Errorf("failed to copy shipments: %w", err)
+	}
+	slog.Info("Copied shipments", "count", shipments)
+
+	for _, check := range []struct{ source, target string }{
+		{"customers", "customer_refs"},
+		{"shipments", "shipments"},
+	} {
+		if err := compareCounts(ctx, src, dst, check.source, check.target); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Split done", "duration", time.Since(start).Round(time.Millisecond).String())
+	return nil
+}
+
+// copyTable pages through the source by the first column (a UUID, compared
+// as text) and inserts each page into the target in one transaction.
+func copyTable(ctx context.Context, src, dst *pgxpool.Pool, batch int, selectQuery, insertQuery string, columns int) (int, error) {
+	copied := 0
+	after := ""
+
+	for {
+		rows, err := src.Query(ctx, selectQuery, after, batch)
+		if err != nil {
+			return copied, err
+		}
+
+		var page [][]any
+		for rows.Next() {
+			values, err := rows.Values()
+			if err != nil {
+				rows.Close()
+				return copied, err
+			}
+			if len(values) != columns {
+				rows.Close()
+				return copied, fmt.Errorf("expected %d columns, got %d", columns, len(values))
+			}
+			page = append(page, values)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return copied, err
+		}
+
+		if len(page) == 0 {
+			return copied, nil
+		}
+
+		err = pgx.BeginFunc(ctx, dst, func(tx pgx.Tx) error {
+			b := &pgx.Batch{}
+			for _, values := range page {
+				b.Queue(insertQuery, values...)
+			}
+			return tx.SendBatch(ctx, b).Close()
+		})
+		if err != nil {
+			return copied, err
+		}
+
+		copied += len(page)
+		after, err = idText(page[len(page)-1][0])
+		if err != nil {
+			return copied, err
+		}
+	}
+}
+
+func idText(v any) (string, error) {
+	switch id := v.(type) {
+	case [16]byte:
+		return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16]), nil
+	case string:
+		return id, nil
+	}
+	return "", fmt.Errorf("unexpected id type %T", v)
+}
+
+func compareCounts(ctx context.Context, src, dst *pgxpool.Pool, sourceTable, targetTable string) error {
+	var want, got int64
+	if err := src.QueryRow(ctx, "SELECT count(*) FROM "+sourceTable).Scan(&want); err != nil {
+		return fmt.Errorf("failed to count %s in source: %w", sourceTable, err)
+	}
+	if err := dst.QueryRow(ctx, "SELECT count(*) FROM "+targetTable).Scan(&got); err != nil {
+		return fmt.Errorf("failed to count %s in target: %w", targetTable, err)
+	}
+	if got < want {
+		return fmt.Errorf("target %s has %d rows, source %s has %d", targetTable, got, sourceTable, want)
+	}
+	slog.Info("Verified row counts",
+		"source_table", sourceTable, "source_rows", want,
+		"target_table", targetTable, "target_rows", got)
+	return nil
+}
@@ -0,0 +1,238 @@
+// Package audit records who changed what in an append-only audit_log kept
+// in the same database as the data.
+//
+// Repositories write an Entry in the transaction of each mutation, so a
+// change is never committed without its entry. Every entry stores the hash
+// of the previous one and a hash over its own content; Verify walks the log
+// and reports the first entry that was modified, removed or reordered.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"testovoe/internal/reqctx"
+)
+
+// Actions.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+)
+
+// Entity types.
+const (
+	EntityCustomer = "customer"
+	EntityShipment = "shipment"
+)
+
+// AnonymousActor is recorded when the context carries no actor.
+const AnonymousActor = "anonymous"
+
+// ErrTampered is returned by Verify when the hash chain does not match the
+// stored entries.
+var ErrTampered = errors.New("audit log hash chain is broken")
+
+// Entry is one row of audit_log. Before and After hold only the fields that
+// changed; Before is nil for creations.
+type Entry struct {
+	Sequence   int64           `json:"sequence"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestID  string          `json:"request_id,omitempty"`
+	TraceID    string          `json:"trace_id,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// Filter selects entries for List. Zero fields match everything. Entries
+// are returned in sequence order starting after After.
+type Filter struct {
+	EntityType string
+	EntityID   string
+	Actor      string
+	Action     string
+	RequestID  string
+	Since      time.Time
+	Until      time.Time
+	After      int64
+	Limit      int
+}
+
+// Store is implemented by the repositories that keep an audit log.
+type Store interface {
+	ListAudit(ctx context.Context, filter Filter) ([]*Entry, error)
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx whose mutations are recorded as made by
+// actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor of ctx, or AnonymousActor.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+// NewEntry describes the change of an entity from before to after made in
+// ctx. before and after are anything that marshals to a JSON object; before
+// is nil for creations. The entry is sealed when it is appended.
+func NewEntry(ctx context.Context, action, entityType, entityID string, before, after any) (*Entry, error) {
+	b, a, err := diff(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s %s: %w", entityType, entityID, err)
+	}
+
+	e := &Entry{
+		// Postgres keeps microseconds; the hash must survive a round trip.
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		Actor:      Actor(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     b,
+		After:      a,
+		RequestID:  reqctx.RequestID(ctx),
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		e.TraceID = sc.TraceID().String()
+	}
+	return e, nil
+}
+
+// diff returns the fields of before and after whose values differ.
+func diff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for k, av := range a {
+		if bv, ok := b[k]; ok && string(bv) == string(av) {
+			delete(a, k)
+			delete(b, k)
+		}
+	}
+
+	bj, err := marshalFields(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	aj, err := marshalFields(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bj, aj, nil
+}
+
+func fields(v any) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func marshalFields(m map[string]json.RawMessage) (json.RawMessage, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	// Map keys are sorted, so the encoding is stable.
+	return json.Marshal(m)
+}
+
+// seal links e to the entry whose hash is prev.
+func (e *Entry) seal(prev string) {
+	e.PrevHash = prev
+	e.Hash = e.computeHash()
+}
+
+// computeHash hashes everything but the sequence, which the database
+// assigns; the link to the previous entry already fixes the order.
+func (e *Entry) computeHash() string {
+	content, _ := json.Marshal(struct {
+		PrevHash   string          `json:"prev_hash"`
+		OccurredAt string          `json:"occurred_at"`
+		Actor      string          `json:"actor"`
+		Action     string          `json:"action"`
+		EntityType string          `json:"entity_type"`
+		EntityID   string          `json:"entity_id"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		RequestID  string          `json:"request_id"`
+		TraceID    string          `json:"trace_id"`
+	}{
+		PrevHash:   e.PrevHash,
+		OccurredAt: e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		Actor:      e.Actor,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		Before:     e.Before,
+		After:      e.After,
+		RequestID:  e.RequestID,
+		TraceID:    e.TraceID,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyPageSize is how many entries Verify reads at a time.
+const verifyPageSize = 500
+
+// Verify checks the whole log of s in sequence order and returns the
+// number of entries checked. A broken chain is reported as ErrTampered
+// naming the first bad sequence.
+func Verify(ctx context.Context, s Store) (int64, error) {
+	var (
+		prev    string
+		checked int64
+		after   int64
+	)
+	for {
+		entries, err := s.ListAudit(ctx, Filter{After: after, Limit: verifyPageSize})
+		if err != nil {
+			return checked, err
+		}
+		for _, e := range entries {
+			if e.PrevHash != prev || e.computeHash() != e.Hash {
+				return checked, fmt.Errorf("%w at sequence %d", ErrTampered, e.Sequence)
+			}
+			prev = e.Hash
+			after = e.Sequence
+			checked++
+		}
+		if len(entries) < verifyPageSize {
+			return checked, nil
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"testovoe/internal/problem"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// ListHandler serves the entries of s matching the query parameters
+// entity_type, entity_id, actor, action, request_id, since and until
+// (RFC 3339), after (sequence) and limit. The response carries next_after
+// to request the following page.
+func ListHandler(s Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseFilter(r.URL.Query())
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		entries, err := s.ListAudit(r.Context(), filter)
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, "failed to list audit log: "+err.Error())
+			return
+		}
+
+		next := filter.After
+		if n := len(entries); n > 0 {
+			next = entries[n-1].Sequence
+		}
+		if entries == nil {
+			entries = []*Entry{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Entries   []*Entry `json:"entries"`
+			NextAfter int64    `json:"next_after"`
+		}{entries, next})
+	})
+}
+
+// VerifyHandler checks the whole hash chain of s. It answers 200 when the
+// chain is intact and 409 Conflict when it is broken.
+func VerifyHandler(s Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checked, err := Verify(r.Context(), s)
+
+		result := struct {
+			OK      bool   `json:"ok"`
+			Checked int64  `json:"checked"`
+			Error   string `json:"error,omitempty"`
+		}{OK: err == nil, Checked: checked}
+
+		status := http.StatusOK
+		switch {
+		case errors.Is(err, ErrTampered):
+			slog.ErrorContext(r.Context(), "Audit log verification failed", "error", err)
+			result.Error = err.Error()
+			status = http.StatusConflict
+		case err != nil:
+			problem.Write(w, r, http.StatusInternalServerError, "failed to verify audit log: "+err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+func parseFilter(q url.Values) (Filter, error) {
+	f := Filter{
+		EntityType: q.Get("entity_type"),
+		EntityID:   q.Get("entity_id"),
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		RequestID:  q.Get("request_id"),
+		Limit:      defaultLimit,
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	if v := q.Get("after"); v != "" {
+		if f.After, err = strconv.ParseInt(v, 10, 64); err != nil || f.After < 0 {
+			return f, fmt.Errorf("invalid after %q", v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > maxLimit {
+			return f, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"sync"
+)
+
+// MemoryLog is the audit log of the in-memory repositories. Callers append
+// while holding their own lock, which makes the entry part of the change.
+type MemoryLog struct {
+	mu      sync.RWMutex
+	entries []*Entry
+}
+
+// Append seals e onto the end of the chain and stores a copy of it.
+func (l *MemoryLog) Append(e *Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prev := ""
+	if n := len(l.entries); n > 0 {
+		prev = l.entries[n-1].Hash
+	}
+	e.seal(prev)
+	e.Sequence = int64(len(l.entries) + 1)
+
+	stored := *e
+	l.entries = append(l.entries, &stored)
+}
+
+// List returns the entries matching filter.
+func (l *MemoryLog) List(filter Filter) []*Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var entries []*Entry
+	// Sequences start at 1 and have no gaps, as in ListEvents.
+	for i := max(filter.After, 0); i < int64(len(l.entries)) && len(entries) < filter.Limit; i++ {
+		e := l.entries[i]
+		if !filter.matches(e) {
+			continue
+		}
+		entry := *e
+		entries = append(entries, &entry)
+	}
+	return entries
+}
+
+func (f Filter) matches(e *Entry) bool {
+	switch {
+	case f.EntityType != "" && e.EntityType != f.EntityType,
+		f.EntityID != "" && e.EntityID != f.EntityID,
+		f.Actor != "" && e.Actor != f.Actor,
+		f.Action != "" && e.Action != f.Action,
+		f.RequestID != "" && e.RequestID != f.RequestID,
+		!f.Since.IsZero() && e.OccurredAt.Before(f.Since),
+		!f.Until.IsZero() && !e.OccurredAt.Before(f.Until):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// lockKey is the transaction-level advisory lock that serialises appends,
+// so no two entries link to the same predecessor.
+const lockKey = 0x61756469745f6c67 // "audit_lg"
+
+// AppendPostgres seals e onto the end of the chain and inserts it in tx.
+func AppendPostgres(ctx context.Context, tx pgx.Tx, e *Entry) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+
+	var prev string
+	err := tx.QueryRow(ctx, `SELECT hash FROM audit_log ORDER BY sequence DESC LIMIT 1`).Scan(&prev)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to read audit log head: %w", err)
+	}
+	e.seal(prev)
+
+	query := `INSERT INTO audit_log (occurred_at, actor, action, entity_type, entity_id,
+			before_state, after_state, request_id, trace_id, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING sequence`
+	err = tx.QueryRow(ctx, query,
+		e.OccurredAt,
+		e.Actor,
+		e.Action,
+		e.EntityType,
+		e.EntityID,
+		nullJSON(e.Before),
+		nullJSON(e.After),
+		e.RequestID,
+		e.TraceID,
+		e.PrevHash,
+		e.Hash,
+	).Scan(&e.Sequence)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// ListPostgres returns the entries of db matching filter.
+func ListPostgres(ctx context.Context, db Querier, filter Filter) ([]*Entry, error) {
+	query := `SELECT sequence, occurred_at, actor, action, entity_type, entity_id,
+			before_state, after_state, request_id, trace_id, prev_hash, hash
+		FROM audit_log
+		WHERE sequence > $1
+			AND ($2 = '' OR entity_type = $2)
+			AND ($3 = '' OR entity_id = $3)
+			AND ($4 = '' OR actor = $4)
+			AND ($5 = '' OR action = $5)
+			AND ($6 = '' OR request_id = $6)
+			AND ($7::timestamptz IS NULL OR occurred_at >= $7)
+			AND ($8::timestamptz IS NULL OR occurred_at < $8)
+		ORDER BY sequence
+		LIMIT $9`
+
+	rows, err := db.Query(ctx, query,
+		filter.After,
+		filter.EntityType,
+		filter.EntityID,
+		filter.Actor,
+		filter.Action,
+		filter.RequestID,
+		nullTime(filter.Since),
+		nullTime(filter.Until),
+		filter.Limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		var (
+			e             Entry
+			before, after *string
+		)
+		if err := rows.Scan(
+			&e.Sequence,
+			&e.OccurredAt,
+			&e.Actor,
+			&e.Action,
+			&e.EntityType,
+			&e.EntityID,
+			&before,
+			&after,
+			&e.RequestID,
+			&e.TraceID,
+			&e.PrevHash,
+			&e.Hash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.OccurredAt = e.OccurredAt.UTC()
+		e.Before, e.After = rawJSON(before), rawJSON(after)
+		entries = append(entries, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+func nullJSON(v []byte) *string {
+	if v == nil {
+		return nil
+	}
+	s := string(v)
+	return &s
+}
+
+func rawJSON(s *string) []byte {
+	if s == nil {
+		return nil
+	}
+	return []byte(*s)
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqliteTimeFormat has a fixed width so that occurred_at, stored as text,
+// sorts and compares in time order.
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+// AppendSQLite seals e onto the end of the chain and inserts it in tx.
+// SQLite runs one write transaction at a time, so reading the head and
+// inserting cannot interleave with another append.
+func AppendSQLite(ctx context.Context, tx *sql.Tx, e *Entry) error {
+	var prev string
+	err := tx.QueryRowContext(ctx, `SELECT hash FROM audit_log ORDER BY sequence DESC LIMIT 1`).Scan(&prev)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read audit log head: %w", err)
+	}
+	e.seal(prev)
+
+	query := `INSERT INTO audit_log (occurred_at, actor, action, entity_type, entity_id,
+			before_state, after_state, request_id, trace_id, prev_hash, hash)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)`
+	res, err := tx.ExecContext(ctx, query,
+		e.OccurredAt.UTC().Format(sqliteTimeFormat),
+		e.Actor,
+		e.Action,
+		e.EntityType,
+		e.EntityID,
+		nullJSON(e.Before),
+		nullJSON(e.After),
+		e.RequestID,
+		e.TraceID,
+		e.PrevHash,
+		e.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	if e.Sequence, err = res.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListSQLite returns the entries of db matching filter.
+func ListSQLite(ctx context.Context, db *sql.DB, filter Filter) ([]*Entry, error) {
+	query := `SELECT sequence, occurred_at, actor, action, entity_type, entity_id,
+			before_state, after_state, request_id, trace_id, prev_hash, hash
+		FROM audit_log
+		WHERE sequence > ?1
+			AND (?2 = '' OR entity_type = ?2)
+			AND (?3 = '' OR entity_id = ?3)
+			AND (?4 = '' OR actor = ?4)
+			AND (?5 = '' OR action = ?5)
+			AND (?6 = '' OR request_id = ?6)
+			AND (?7 = '' OR occurred_at >= ?7)
+			AND (?8 = '' OR occurred_at < ?8)
+		ORDER BY sequence
+		LIMIT ?9`
+
+	rows, err := db.QueryContext(ctx, query,
+		filter.After,
+		filter.EntityType,
+		filter.EntityID,
+		filter.Actor,
+		filter.Action,
+		filter.RequestID,
+		sqliteTime(filter.Since),
+		sqliteTime(filter.Until),
+		filter.Limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		var (
+			e             Entry
+			occurredAt    string
+			before, after *string
+		)
+		if err := rows.Scan(
+			&e.Sequence,
+			&occurredAt,
+			&e.Actor,
+			&e.Action,
+			&e.EntityType,
+			&e.EntityID,
+			&before,
+			&after,
+			&e.RequestID,
+			&e.TraceID,
+			&e.PrevHash,
+			&e.Hash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if e.OccurredAt, err = time.Parse(sqliteTimeFormat, occurredAt); err != nil {
+			return nil, fmt.Errorf("failed to parse audit entry time: %w", err)
+		}
+		e.Before, e.After = rawJSON(before), rawJSON(after)
+		entries = append(entries, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+func sqliteTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(sqliteTimeFormat)
+}
@@ -0,0 +1,205 @@
+// Package auth authenticates clients of shipment-service with bearer JWTs
+// signed by keys from a local JWKS file.
+//
+// Middleware (REST) and UnaryServerInterceptor (gRPC) verify the token,
+// require sub, exp and the scope for the call, and put the caller into the
+// request context as a Principal. The service layer reads it with
+// FromContext to record the actor of a change and to check ownership.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"testovoe/internal/logging"
+	"testovoe/internal/problem"
+)
+
+// leeway absorbs clock skew between the token issuer and this service.
+const leeway = 30 * time.Second
+
+// Principal is the authenticated caller.
+type Principal struct {
+	Subject string
+	Scopes  []string
+}
+
+// HasScope reports whether the token of p granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of ctx. It reports false for requests
+// that were not authenticated, such as internal gRPC calls.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// Config is what Middleware and UnaryServerInterceptor require of a token. Issuer and Audience are
+// checked only when set.
+type Config struct {
+	Issuer   string
+	Audience string
+	// ReadScope is required for GET and HEAD and for read-only RPCs,
+	// WriteScope for everything else.
+	ReadScope  string
+	WriteScope string
+}
+
+// Authenticator checks bearer tokens against a KeySet.
+type Authenticator struct {
+	keys   *KeySet
+	cfg    Config
+	parser *jwt.Parser
+}
+
+func NewAuthenticator(keys *KeySet, cfg Config) *Authenticator {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &Authenticator{keys: keys, cfg: cfg, parser: jwt.NewParser(opts...)}
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	// Scope is the space-separated OAuth 2.0 form (RFC 8693); Scp is the
+	// list some identity providers send instead.
+	Scope string           `json:"scope"`
+	Scp   jwt.ClaimStrings `json:"scp"`
+}
+
+// Middleware rejects requests without a valid token with 401 and requests
+// whose token lacks the scope for the method with 403. It must run inside
+// the tracing middleware and reqctx.Middleware so that the error responses
+// carry the request ID.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write := r.Method != http.MethodGet && r.Method != http.MethodHead
+
+		ctx, err := a.authorize(r.Context(), r.Header.Get("Authorization"), write)
+		var scopeErr *scopeError
+		switch {
+		case errors.As(err, &scopeErr):
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scopeErr.scope))
+			problem.Write(w, r.WithContext(ctx), http.StatusForbidden, "token lacks scope "+scopeErr.scope)
+			return
+		case errors.Is(err, errNoToken):
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			problem.Write(w, r, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		case err != nil:
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			problem.Write(w, r, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+var errNoToken = errors.New("no bearer token")
+
+// scopeError is returned by authorize for a valid token without the
+// required scope.
+type scopeError struct {
+	scope string
+}
+
+func (e *scopeError) Error() string {
+	return "token lacks scope " + e.scope
+}
+
+// authorize checks the Authorization value of a request and the scope for
+// a read or write call. On success, and for a scopeError, the returned
+// context carries the principal. Failures are logged here so that the HTTP
+// and gRPC responses can stay terse.
+func (a *Authenticator) authorize(ctx context.Context, authorization string, write bool) (context.Context, error) {
+	p, err := a.authenticate(authorization)
+	if err != nil {
+		slog.WarnContext(ctx, "Authentication failed", "error", err)
+		return ctx, err
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("enduser.id", p.Subject),
+		attribute.String("enduser.scope", strings.Join(p.Scopes, " ")),
+	)
+	ctx = logging.WithAttrs(WithPrincipal(ctx, p), slog.String("enduser.id", p.Subject))
+
+	scope := a.cfg.ReadScope
+	if write {
+		scope = a.cfg.WriteScope
+	}
+	if !p.HasScope(scope) {
+		slog.WarnContext(ctx, "Insufficient scope", "required", scope)
+		return ctx, &scopeError{scope: scope}
+	}
+	return ctx, nil
+}
+
+// authenticate verifies the bearer token in an Authorization value.
+func (a *Authenticator) authenticate(authorization string) (*Principal, error) {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, errNoToken
+	}
+
+	var c claims
+	if _, err := a.parser.ParseWithClaims(token, &c, a.keyFunc); err != nil {
+		return nil, err
+	}
+	if c.Subject == "" {
+		return nil, errors.New("token has no sub claim")
+	}
+
+	scopes := strings.Fields(c.Scope)
+	for _, s := range c.Scp {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("token has no scope claim")
+	}
+
+	return &Principal{Subject: c.Subject, Scopes: scopes}, nil
+}
+
+func (a *Authenticator) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, ok := a.keys.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if k.alg != "" && k.alg != t.Method.Alg() {
+		return nil, fmt.Errorf("key %q is for %s, token uses %s", kid, k.alg, t.Method.Alg())
+	}
+	return k.public, nil
+}
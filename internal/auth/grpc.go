@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthService is left open: probes carry no token.
+const healthService = "/grpc.health.v1.Health/"
+
+// UnaryServerInterceptor applies the checks of Middleware to gRPC calls,
+// reading the token from the authorization metadata. readOnly reports
+// whether a full method name needs only the read scope. Failures are
+// returned as Unauthenticated and PermissionDenied. It must run after
+// reqctx.EdgeUnaryServerInterceptor so that the logs carry the request ID.
+func (a *Authenticator) UnaryServerInterceptor(readOnly func(fullMethod string) bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(ctx, req)
+		}
+
+		var authorization string
+		if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+
+		ctx, err := a.authorize(ctx, authorization, !readOnly(info.FullMethod))
+		var scopeErr *scopeError
+		switch {
+		case errors.As(err, &scopeErr):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case err != nil:
+			return nil, status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+		}
+		return handler(ctx, req)
+	}
+}
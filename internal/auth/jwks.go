@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// minRSABits is the smallest RSA modulus accepted from the key file.
+const minRSABits = 2048
+
+// KeySet holds the public keys from a JWKS file (RFC 7517). Only signing
+// keys usable with RS256 (kty RSA) and ES256 (kty EC, crv P-256) are kept;
+// the others are skipped.
+type KeySet struct {
+	path string
+	keys atomic.Pointer[keys]
+}
+
+type keys struct {
+	byKID   map[string]*key
+	modTime time.Time
+	size    int64
+}
+
+type key struct {
+	alg    string // "RS256", "ES256" or empty when the JWK does not say
+	public crypto.PublicKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewKeySet reads the JWKS file at path. The file must contain at least one
+// usable key.
+func NewKeySet(path string) (*KeySet, error) {
+	s := &KeySet{path: path}
+	if _, err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run rereads the file every interval when its modification time or size
+// changed. A file that fails to load is logged and the previous keys stay
+// in use. Run returns when ctx is done.
+func (s *KeySet) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := s.reload()
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to reload JWKS, keeping previous keys", "path", s.path, "error", err)
+			continue
+		}
+		if reloaded {
+			slog.InfoContext(ctx, "Reloaded JWKS", "path", s.path, "keys", len(s.keys.Load().byKID))
+		}
+	}
+}
+
+// lookup returns the key for kid. A token without kid is accepted only
+// when the set holds a single key.
+func (s *KeySet) lookup(kid string) (*key, bool) {
+	byKID := s.keys.Load().byKID
+	if kid == "" && len(byKID) == 1 {
+		for _, k := range byKID {
+			return k, true
+		}
+	}
+	k, ok := byKID[kid]
+	return k, ok
+}
+
+// reload reads the file when it changed since the last load and reports
+// whether the keys were replaced.
+func (s *KeySet) reload() (bool, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat JWKS file: %w", err)
+	}
+	if cur := s.keys.Load(); cur != nil && cur.modTime.Equal(info.ModTime()) && cur.size == info.Size() {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	byKID, err := parseJWKS(s.path, data)
+	if err != nil {
+		return false, fmt.Errorf("invalid JWKS file %s: %w", s.path, err)
+	}
+
+	s.keys.Store(&keys{byKID: byKID, modTime: info.ModTime(), size: info.Size()})
+	return true, nil
+}
+
+// parseJWKS returns the usable keys of a JWKS document. Keys this service
+// cannot use, such as OKP or P-384 keys published alongside, are logged
+// and skipped, so that they do not lock out tokens signed with the others.
+func parseJWKS(path string, data []byte) (map[string]*key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	byKID := make(map[string]*key, len(set.Keys))
+	for i, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		k, err := j.parse()
+		if err != nil {
+			slog.Warn("Skipping unusable JWKS key", "path", path, "index", i, "kid", j.Kid, "error", err)
+			continue
+		}
+		if _, dup := byKID[j.Kid]; dup {
+			slog.Warn("Skipping JWKS key with duplicate kid", "path", path, "index", i, "kid", j.Kid)
+			continue
+		}
+		byKID[j.Kid] = k
+	}
+
+	if len(byKID) == 0 {
+		return nil, errors.New("no usable signing keys")
+	}
+	return byKID, nil
+}
+
+func (j jwk) parse() (*key, error) {
+	switch j.Kty {
+	case "RSA":
+		if j.Alg != "" && j.Alg != "RS256" {
+			return nil, fmt.Errorf("unsupported alg %q for RSA key", j.Alg)
+		}
+		n, err := decodeInt(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeInt(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e: %w", err)
+		}
+		if n.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key is %d bits, need at least %d", n.BitLen(), minRSABits)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &key{alg: j.Alg, public: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		if j.Alg != "" && j.Alg != "ES256" {
+			return nil, fmt.Errorf("unsupported alg %q for EC key", j.Alg)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != 32 {
+			return nil, errors.New("invalid x")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil || len(y) != 32 {
+			return nil, errors.New("invalid y")
+		}
+		// ecdh rejects points that are not on the curve.
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("invalid EC point: %w", err)
+		}
+		return &key{alg: j.Alg, public: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported kty %q", j.Kty)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
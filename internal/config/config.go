@@ -0,0 +1,725 @@
+// Package config loads the typed configuration shared by customer-service
+// and shipment-service.
+//
+// Values are resolved in this order, later sources overriding earlier ones:
+// built-in defaults, the YAML file given by -config or CONFIG_FILE,
+// environment variables, command-line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CustomerService = "customer-service"
+	ShipmentService = "shipment-service"
+)
+
+// Storage backends selectable with -storage / STORAGE.
+const (
+	StoragePostgres = "postgres"
+	// StorageSQLite keeps data in a local SQLite file (see SQLiteConfig);
+	// it is meant for development without a database server.
+	StorageSQLite = "sqlite"
+	// StorageMemory keeps all data in process memory; it needs no database
+	// and loses everything on restart.
+	StorageMemory = "memory"
+)
+
+// Metrics exporters selectable with -otel.metrics-exporter /
+// OTEL_METRICS_EXPORTER.
+const (
+	MetricsExporterOTLP       = "otlp"
+	MetricsExporterPrometheus = "prometheus"
+	// MetricsExporterBoth pushes over OTLP and serves /metrics.
+	MetricsExporterBoth = "both"
+	MetricsExporterNone = "none"
+)
+
+// Trace exporters selectable with -otel.traces-exporter /
+// OTEL_TRACES_EXPORTER.
+const (
+	TracesExporterOTLP = "otlp"
+	// TracesExporterStdout prints spans as JSON to stdout.
+	TracesExporterStdout = "stdout"
+	// TracesExporterFile appends spans as JSON lines to TracesFile.
+	TracesExporterFile = "file"
+	TracesExporterNone = "none"
+)
+
+// Log exporters selectable with -otel.logs-exporter / OTEL_LOGS_EXPORTER.
+const (
+	LogsExporterOTLP = "otlp"
+	LogsExporterNone = "none"
+)
+
+const redacted = "******"
+
+type Config struct {
+	Storage   string          `yaml:"storage"`
+	DB        DBConfig        `yaml:"db"`
+	SQLite    SQLiteConfig    `yaml:"sqlite"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	HTTP      HTTPConfig      `yaml:"http"`
+	Customer  CustomerConfig  `yaml:"customer,omitempty"`
+	Auth      AuthConfig      `yaml:"auth,omitempty"`
+	Telemetry TelemetryConfig `yaml:"telemetry"`
+	Admin     AdminConfig     `yaml:"admin"`
+	Log       LogConfig       `yaml:"log"`
+	Shutdown  ShutdownConfig  `yaml:"shutdown"`
+	// Runtime holds the settings that can change without a restart; see
+	// Reloader.
+	Runtime RuntimeConfig `yaml:"runtime"`
+
+	service string
+	file    string
+	args    []string
+}
+
+type DBConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// PasswordFile, when set, replaces Password with the contents of the
+	// file (for Docker/Kubernetes secrets).
+	PasswordFile string `yaml:"password_file"`
+	Name         string `yaml:"name"`
+
+	// SSLMode is passed to the driver as sslmode: disable, require,
+	// verify-ca or verify-full. The verify modes need SSLRootCert.
+	SSLMode     string `yaml:"sslmode"`
+	SSLRootCert string `yaml:"sslrootcert"`
+
+	// MaxConns caps the pool size; 0 keeps the pgxpool default. MinConns
+	// connections are kept open even when idle.
+	MaxConns        int32         `yaml:"max_conns"`
+	MinConns        int32         `yaml:"min_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+
+	// AutoMigrate applies pending migrations on startup. The migrate
+	// subcommand works regardless of this setting.
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+type SQLiteConfig struct {
+	// Path is the database file, created on first start.
+	Path string `yaml:"path"`
+}
+
+type GRPCConfig struct {
+	Port           string        `yaml:"port"`
+	Reflection     bool          `yaml:"reflection"`
+	HealthInterval time.Duration `yaml:"health_interval"`
+	DrainTimeout   time.Duration `yaml:"drain_timeout"`
+}
+
+type HTTPConfig struct {
+	Port string `yaml:"port"`
+}
+
+// CustomerEndpointMemory as customer.endpoint replaces customer-service
+// with an in-memory stand-in, for local demos without dependencies.
+const CustomerEndpointMemory = "memory"
+
+// CustomerConfig describes how shipment-service reaches customer-service.
+type CustomerConfig struct {
+	// Endpoint is the gRPC address of customer-service, or
+	// CustomerEndpointMemory.
+	Endpoint string `yaml:"endpoint"`
+	// SyncInterval is how often the customer event feed is polled to update
+	// the local customer references; 0 disables the sync.
+	SyncInterval time.Duration `yaml:"sync_interval"`
+}
+
+// AuthConfig describes the JWT authentication of the shipment-service
+// REST API.
+type AuthConfig struct {
+	// JWKSFile holds the public keys tokens are verified with. It is
+	// reread every JWKSReloadInterval when it changed.
+	JWKSFile           string        `yaml:"jwks_file"`
+	JWKSReloadInterval time.Duration `yaml:"jwks_reload_interval"`
+	// Issuer and Audience are checked against iss and aud when set.
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// ReadScope is required for GET requests, WriteScope for the rest.
+	ReadScope  string `yaml:"read_scope"`
+	WriteScope string `yaml:"write_scope"`
+	// Disabled turns authentication off. The service refuses to start
+	// with neither JWKSFile nor Disabled set, so an open API is always a
+	// deliberate choice.
+	Disabled bool `yaml:"disabled"`
+}
+
+type TelemetryConfig struct {
+	// Endpoint is the OTLP gRPC collector address. An https:// prefix
+	// turns TLS on and http:// turns it off regardless of Insecure.
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"service_name"`
+	// Environment is reported as deployment.environment, e.g. production.
+	Environment string `yaml:"environment"`
+	// ShutdownTimeout bounds the final flush of spans, metrics and logs.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// Insecure disables TLS for an endpoint given without a scheme.
+	// Certificate is a PEM CA bundle to verify the collector; the system
+	// pool is used when it is empty.
+	Insecure    bool   `yaml:"insecure"`
+	Certificate string `yaml:"certificate"`
+	// Headers are sent with every OTLP export, e.g. an API key.
+	// HeadersFile, when set, replaces Headers with the key=value pairs in
+	// the file.
+	Headers     map[string]string `yaml:"headers"`
+	HeadersFile string            `yaml:"headers_file"`
+
+	// TracesExporter is otlp, stdout, file or none.
+	TracesExporter string `yaml:"traces_exporter"`
+	TracesFile     string `yaml:"traces_file"`
+	// TracesSampler uses the OTEL_TRACES_SAMPLER names: always_on,
+	// always_off, traceidratio and their parentbased_ variants.
+	// TracesSamplerArg is the ratio for the traceidratio samplers.
+	TracesSampler    string  `yaml:"traces_sampler"`
+	TracesSamplerArg float64 `yaml:"traces_sampler_arg"`
+
+	// MetricsExporter is otlp, prometheus, both or none. Prometheus
+	// metrics are served on the admin port.
+	MetricsExporter string `yaml:"metrics_exporter"`
+	// LogsExporter is otlp or none. With otlp every log record is also
+	// sent to the collector as an OTel log.
+	LogsExporter string `yaml:"logs_exporter"`
+	// PIIRedaction is how customer IDNs appear in spans and logs: mask,
+	// hash or none. PIIHashKey keys the hash; keep it secret so hashes
+	// cannot be reversed by trying every IDN. PIIHashKeyFile, when set,
+	// replaces PIIHashKey with the contents of the file.
+	PIIRedaction   string `yaml:"pii_redaction"`
+	PIIHashKey     string `yaml:"pii_hash_key"`
+	PIIHashKeyFile string `yaml:"pii_hash_key_file"`
+}
+
+// PrometheusEnabled reports whether /metrics is served.
+func (c TelemetryConfig) PrometheusEnabled() bool {
+	return c.MetricsExporter == MetricsExporterPrometheus || c.MetricsExporter == MetricsExporterBoth
+}
+
+// OTLPMetricsEnabled reports whether metrics are pushed to the collector.
+func (c TelemetryConfig) OTLPMetricsEnabled() bool {
+	return c.MetricsExporter == MetricsExporterOTLP || c.MetricsExporter == MetricsExporterBoth
+}
+
+// LogConfig controls log output. The level is a runtime setting; see
+// RuntimeConfig.LogLevel.
+type LogConfig struct {
+	// Format is json or text.
+	Format string `yaml:"format"`
+}
+
+// AdminConfig describes the internal HTTP port for operational endpoints
+// that should not be reachable through Envoy.
+type AdminConfig struct {
+	Port string `yaml:"port"`
+}
+
+type ShutdownConfig struct {
+	// DrainDelay is how long readiness reports failing before the HTTP
+	// server starts shutting down.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+}
+
+// RuntimeConfig is the hot-reloadable part of the configuration.
+type RuntimeConfig struct {
+	LogLevel  string          `yaml:"log_level" json:"log_level"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+	Retry     RetryConfig     `yaml:"retry" json:"retry"`
+}
+
+// RateLimitConfig limits incoming API requests per process. RPS 0 disables
+// the limit.
+type RateLimitConfig struct {
+	RPS   float64 `yaml:"rps" json:"rps"`
+	Burst int     `yaml:"burst" json:"burst"`
+}
+
+// RetryConfig controls retries of outgoing calls to other services.
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts" json:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" json:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" json:"max_backoff"`
+}
+
+// Defaults returns the configuration used when no source sets a value.
+// There is deliberately no default database password.
+func Defaults(service string) *Config {
+	cfg := &Config{
+		Storage: StoragePostgres,
+		SQLite: SQLiteConfig{
+			Path: service + ".db",
+		},
+		DB: DBConfig{
+			Host:            "localhost",
+			Port:            "5432",
+			User:            "postgres",
+			Name:            "testovoe",
+			SSLMode:         "disable",
+			MaxConns:        20,
+			MinConns:        2,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		GRPC: GRPCConfig{
+			HealthInterval: 5 * time.Second,
+			DrainTimeout:   10 * time.Second,
+		},
+		Telemetry: TelemetryConfig{
+			Endpoint:         "localhost:4317",
+			ServiceName:      service,
+			Environment:      "development",
+			ShutdownTimeout:  5 * time.Second,
+			Insecure:         true,
+			TracesExporter:   TracesExporterOTLP,
+			TracesFile:       service + "-traces.json",
+			TracesSampler:    "parentbased_always_on",
+			TracesSamplerArg: 1,
+			MetricsExporter:  MetricsExporterOTLP,
+			LogsExporter:     LogsExporterNone,
+			PIIRedaction:     "mask",
+		},
+		Log: LogConfig{
+			Format: "json",
+		},
+		Shutdown: ShutdownConfig{
+			DrainDelay: 5 * time.Second,
+		},
+		Runtime: RuntimeConfig{
+			LogLevel: "info",
+			Retry: RetryConfig{
+				MaxAttempts:    3,
+				InitialBackoff: 100 * time.Millisecond,
+				MaxBackoff:     time.Second,
+			},
+		},
+		service: service,
+	}
+
+	switch service {
+	case CustomerService:
+		cfg.GRPC.Port = "9090"
+		cfg.HTTP.Port = "8081"
+		cfg.Admin.Port = "9464"
+	case ShipmentService:
+		cfg.GRPC.Port = "9091"
+		cfg.HTTP.Port = "8080"
+		cfg.Admin.Port = "9465"
+		cfg.Customer.Endpoint = "localhost:9090"
+		cfg.Customer.SyncInterval = 5 * time.Second
+		cfg.Auth.JWKSReloadInterval = time.Minute
+		cfg.Auth.ReadScope = "shipments:read"
+		cfg.Auth.WriteScope = "shipments:write"
+	}
+
+	return cfg
+}
+
+// Load builds the configuration for service from defaults, the config file,
+// the environment and args, then validates it. When -print-config is given
+// the redacted configuration is written to stdout and Load exits.
+func Load(service string, args []string) (*Config, error) {
+	cfg, printConfig, err := load(service, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if printConfig {
+		cfg.Print(os.Stdout)
+		os.Exit(0)
+	}
+
+	return cfg, nil
+}
+
+func load(service string, args []string) (*Config, bool, error) {
+	cfg := Defaults(service)
+	fields := cfg.fields()
+
+	fs := flag.NewFlagSet(service, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file (env CONFIG_FILE)")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+
+	// Flags are parsed first to find -config, but applied last.
+	flagValues := make(map[string]string)
+	for _, f := range fields {
+		name := f.flag
+		fs.Func(name, fmt.Sprintf("%s (env %s)", f.usage, f.env), func(v string) error {
+			flagValues[name] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, false, err
+	}
+
+	cfg.args = fs.Args()
+
+	if *configFile != "" {
+		cfg.file = *configFile
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, false, err
+		}
+	}
+
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.env); ok {
+			if err := f.set(v); err != nil {
+				return nil, false, fmt.Errorf("invalid %s: %w", f.env, err)
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if v, ok := flagValues[f.flag]; ok {
+			if err := f.set(v); err != nil {
+				return nil, false, fmt.Errorf("invalid -%s: %w", f.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, false, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, false, err
+	}
+
+	return cfg, *printConfig, nil
+}
+
+// Args returns the positional arguments left after the flags, such as the
+// command given to the migrate subcommand.
+func (c *Config) Args() []string {
+	return c.args
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// resolveSecrets replaces secrets with the contents of their _FILE
+// counterparts (Docker/Kubernetes secrets).
+func (c *Config) resolveSecrets() error {
+	if c.DB.PasswordFile != "" {
+		password, err := readSecret(c.DB.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("failed to read db password file: %w", err)
+		}
+		c.DB.Password = password
+	}
+
+	if c.Telemetry.PIIHashKeyFile != "" {
+		key, err := readSecret(c.Telemetry.PIIHashKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read pii hash key file: %w", err)
+		}
+		c.Telemetry.PIIHashKey = key
+	}
+
+	if c.Telemetry.HeadersFile != "" {
+		data, err := readSecret(c.Telemetry.HeadersFile)
+		if err != nil {
+			return fmt.Errorf("failed to read otlp headers file: %w", err)
+		}
+		headers, err := parseHeaders(data)
+		if err != nil {
+			return fmt.Errorf("invalid otlp headers file: %w", err)
+		}
+		c.Telemetry.Headers = headers
+	}
+	return nil
+}
+
+// readSecret returns the contents of path without the trailing newline
+// that editors and echo add.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.Storage {
+	case StoragePostgres:
+		errs = append(errs, c.DB.validate())
+	case StorageSQLite:
+		if c.SQLite.Path == "" {
+			errs = append(errs, errors.New("sqlite.path is required"))
+		}
+	case StorageMemory:
+	default:
+		errs = append(errs, fmt.Errorf("storage must be one of %s, %s, %s, got %q",
+			StoragePostgres, StorageSQLite, StorageMemory, c.Storage))
+	}
+
+	errs = append(errs,
+		validatePort("grpc.port", c.GRPC.Port),
+		validatePort("http.port", c.HTTP.Port),
+		validatePort("admin.port", c.Admin.Port),
+	)
+
+	if c.GRPC.HealthInterval <= 0 {
+		errs = append(errs, errors.New("grpc.health_interval must be positive"))
+	}
+	if c.GRPC.DrainTimeout <= 0 {
+		errs = append(errs, errors.New("grpc.drain_timeout must be positive"))
+	}
+	if c.Shutdown.DrainDelay < 0 {
+		errs = append(errs, errors.New("shutdown.drain_delay must not be negative"))
+	}
+	if c.Telemetry.Endpoint == "" {
+		errs = append(errs, errors.New("telemetry.endpoint is required"))
+	}
+	if c.Telemetry.ServiceName == "" {
+		errs = append(errs, errors.New("telemetry.service_name is required"))
+	}
+	errs = append(errs, c.Telemetry.validate())
+	switch c.Telemetry.MetricsExporter {
+	case MetricsExporterOTLP, MetricsExporterNone, MetricsExporterPrometheus, MetricsExporterBoth:
+	default:
+		errs = append(errs, fmt.Errorf("telemetry.metrics_exporter must be one of %s, %s, %s, %s, got %q",
+			MetricsExporterOTLP, MetricsExporterPrometheus, MetricsExporterBoth, MetricsExporterNone, c.Telemetry.MetricsExporter))
+	}
+
+	switch c.Telemetry.LogsExporter {
+	case LogsExporterOTLP, LogsExporterNone:
+	default:
+		errs = append(errs, fmt.Errorf("telemetry.logs_exporter must be %s or %s, got %q",
+			LogsExporterOTLP, LogsExporterNone, c.Telemetry.LogsExporter))
+	}
+	switch c.Telemetry.PIIRedaction {
+	case "mask", "none":
+	case "hash":
+		if c.Telemetry.PIIHashKey == "" {
+			errs = append(errs, errors.New("telemetry.pii_hash_key is required for pii_redaction hash (set PII_HASH_KEY or PII_HASH_KEY_FILE)"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("telemetry.pii_redaction must be one of mask, hash, none, got %q", c.Telemetry.PIIRedaction))
+	}
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("log.format must be json or text, got %q", c.Log.Format))
+	}
+
+	errs = append(errs, c.Runtime.validate())
+
+	switch c.service {
+	case ShipmentService:
+		if c.Customer.Endpoint == "" {
+			errs = append(errs, errors.New("customer.endpoint is required"))
+		}
+		if c.Customer.SyncInterval < 0 {
+			errs = append(errs, errors.New("customer.sync_interval must not be negative"))
+		}
+		if c.Auth.Disabled && c.Auth.JWKSFile != "" {
+			errs = append(errs, errors.New("auth.disabled and auth.jwks_file are mutually exclusive"))
+		}
+		if c.Auth.JWKSFile != "" {
+			if c.Auth.JWKSReloadInterval <= 0 {
+				errs = append(errs, errors.New("auth.jwks_reload_interval must be positive"))
+			}
+			if c.Auth.ReadScope == "" || c.Auth.WriteScope == "" {
+				errs = append(errs, errors.New("auth.read_scope and auth.write_scope are required"))
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+func (c DBConfig) validate() error {
+	var errs []error
+
+	if c.Host == "" {
+		errs = append(errs, errors.New("db.host is required"))
+	}
+	if c.User == "" {
+		errs = append(errs, errors.New("db.user is required"))
+	}
+	if c.Password == "" {
+		errs = append(errs, errors.New("db.password is required (set DB_PASSWORD or DB_PASSWORD_FILE)"))
+	}
+	if c.Name == "" {
+		errs = append(errs, errors.New("db.name is required"))
+	}
+	switch c.SSLMode {
+	case "disable", "require":
+	case "verify-ca", "verify-full":
+		if c.SSLRootCert == "" {
+			errs = append(errs, fmt.Errorf("db.sslrootcert is required for sslmode %s", c.SSLMode))
+		} else if _, err := os.Stat(c.SSLRootCert); err != nil {
+			errs = append(errs, fmt.Errorf("db.sslrootcert: %w", err))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("db.sslmode must be one of disable, require, verify-ca, verify-full, got %q", c.SSLMode))
+	}
+	if c.MaxConns < 0 || c.MinConns < 0 {
+		errs = append(errs, errors.New("db pool sizes must not be negative"))
+	}
+	if c.MaxConns > 0 && c.MinConns > c.MaxConns {
+		errs = append(errs, errors.New("db.min_conns must not exceed db.max_conns"))
+	}
+	if c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0 {
+		errs = append(errs, errors.New("db connection lifetimes must not be negative"))
+	}
+
+	errs = append(errs, validatePort("db.port", c.Port))
+
+	return errors.Join(errs...)
+}
+
+func (c TelemetryConfig) validate() error {
+	var errs []error
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("telemetry.shutdown_timeout must be positive"))
+	}
+
+	switch c.TracesExporter {
+	case TracesExporterOTLP, TracesExporterStdout, TracesExporterNone:
+	case TracesExporterFile:
+		if c.TracesFile == "" {
+			errs = append(errs, errors.New("telemetry.traces_file is required for the file exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("telemetry.traces_exporter must be one of %s, %s, %s, %s, got %q",
+			TracesExporterOTLP, TracesExporterStdout, TracesExporterFile, TracesExporterNone, c.TracesExporter))
+	}
+	switch c.TracesSampler {
+	case "always_on", "always_off", "traceidratio",
+		"parentbased_always_on", "parentbased_always_off", "parentbased_traceidratio":
+	default:
+		errs = append(errs, fmt.Errorf("telemetry.traces_sampler: unknown sampler %q", c.TracesSampler))
+	}
+	if c.TracesSamplerArg < 0 || c.TracesSamplerArg > 1 {
+		errs = append(errs, fmt.Errorf("telemetry.traces_sampler_arg must be between 0 and 1, got %v", c.TracesSamplerArg))
+	}
+	if c.Certificate != "" {
+		if _, err := os.Stat(c.Certificate); err != nil {
+			errs = append(errs, fmt.Errorf("telemetry.certificate: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r RuntimeConfig) validate() error {
+	var errs []error
+
+	switch r.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("runtime.log_level must be one of debug, info, warn, error, got %q", r.LogLevel))
+	}
+	if r.RateLimit.RPS < 0 {
+		errs = append(errs, errors.New("runtime.rate_limit.rps must not be negative"))
+	}
+	if r.RateLimit.Burst < 0 {
+		errs = append(errs, errors.New("runtime.rate_limit.burst must not be negative"))
+	}
+	if r.Retry.MaxAttempts < 1 {
+		errs = append(errs, errors.New("runtime.retry.max_attempts must be at least 1"))
+	}
+	if r.Retry.InitialBackoff <= 0 || r.Retry.MaxBackoff < r.Retry.InitialBackoff {
+		errs = append(errs, errors.New("runtime.retry backoffs must be positive and max_backoff >= initial_backoff"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validatePort(name, port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s must be a port number, got %q", name, port)
+	}
+	return nil
+}
+
+// DSN returns the libpq-style connection string for the database.
+func (c DBConfig) DSN() string {
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSN(c.Host), quoteDSN(c.Port), quoteDSN(c.User), quoteDSN(c.Password), quoteDSN(c.Name), quoteDSN(c.SSLMode))
+	if c.SSLRootCert != "" {
+		dsn += " sslrootcert=" + quoteDSN(c.SSLRootCert)
+	}
+	return dsn
+}
+
+// quoteDSN quotes a key/value connection string value so passwords with
+// spaces or quotes survive.
+func quoteDSN(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// Redacted returns a copy of the configuration with secrets masked.
+func (c *Config) Redacted() Config {
+	out := *c
+	if out.DB.Password != "" {
+		out.DB.Password = redacted
+	}
+	if out.Telemetry.PIIHashKey != "" {
+		out.Telemetry.PIIHashKey = redacted
+	}
+	// Headers usually carry credentials; keep the names only.
+	if len(out.Telemetry.Headers) > 0 {
+		headers := make(map[string]string, len(out.Telemetry.Headers))
+		for k := range out.Telemetry.Headers {
+			headers[k] = redacted
+		}
+		out.Telemetry.Headers = headers
+	}
+	return out
+}
+
+// Print writes the redacted configuration as YAML.
+func (c *Config) Print(w io.Writer) {
+	r := c.Redacted()
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	enc.Encode(&r)
+	enc.Close()
+}
+
+// String returns the redacted configuration, so logging a *Config never
+// leaks secrets.
+func (c *Config) String() string {
+	var b strings.Builder
+	c.Print(&b)
+	return b.String()
+}
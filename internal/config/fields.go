@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field binds one setting to its environment variable and flag.
+type field struct {
+	flag  string
+	env   string
+	usage string
+	set   func(string) error
+}
+
+func (c *Config) fields() []field {
+	fields := []field{
+		stringField("storage", "STORAGE", "storage backend: postgres, sqlite or memory", &c.Storage),
+		stringField("sqlite.path", "SQLITE_PATH", "SQLite database file", &c.SQLite.Path),
+		stringField("db.host", "DB_HOST", "database host", &c.DB.Host),
+		stringField("db.port", "DB_PORT", "database port", &c.DB.Port),
+		stringField("db.user", "DB_USER", "database user", &c.DB.User),
+		stringField("db.password", "DB_PASSWORD", "database password", &c.DB.Password),
+		stringField("db.password-file", "DB_PASSWORD_FILE", "file containing the database password", &c.DB.PasswordFile),
+		stringField("db.name", "DB_NAME", "database name", &c.DB.Name),
+		stringField("db.sslmode", "DB_SSLMODE", "disable, require, verify-ca or verify-full", &c.DB.SSLMode),
+		stringField("db.sslrootcert", "DB_SSLROOTCERT", "CA certificate file for verify-ca/verify-full", &c.DB.SSLRootCert),
+		int32Field("db.max-conns", "DB_MAX_CONNS", "maximum pool size, 0 keeps the driver default", &c.DB.MaxConns),
+		int32Field("db.min-conns", "DB_MIN_CONNS", "connections kept open while idle", &c.DB.MinConns),
+		durationField("db.conn-max-lifetime", "DB_CONN_MAX_LIFETIME", "maximum connection lifetime, 0 means unlimited", &c.DB.ConnMaxLifetime),
+		durationField("db.conn-max-idle-time", "DB_CONN_MAX_IDLE_TIME", "maximum connection idle time, 0 means unlimited", &c.DB.ConnMaxIdleTime),
+		boolField("db.auto-migrate", "DB_AUTO_MIGRATE", "apply pending migrations on startup", &c.DB.AutoMigrate),
+		stringField("grpc.port", "GRPC_PORT", "gRPC listen port", &c.GRPC.Port),
+		durationField("grpc.health-interval", "HEALTH_CHECK_INTERVAL", "database health probe interval", &c.GRPC.HealthInterval),
+		durationField("grpc.drain-timeout", "GRPC_DRAIN_TIMEOUT", "how long to wait for in-flight RPCs on shutdown", &c.GRPC.DrainTimeout),
+		stringField("http.port", "HTTP_PORT", "HTTP listen port", &c.HTTP.Port),
+		stringField("otel.endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTLP gRPC endpoint", &c.Telemetry.Endpoint),
+		stringField("otel.service-name", "OTEL_SERVICE_NAME", "service name reported in traces", &c.Telemetry.ServiceName),
+		stringField("otel.environment", "DEPLOYMENT_ENVIRONMENT", "deployment.environment resource attribute", &c.Telemetry.Environment),
+		durationField("otel.shutdown-timeout", "OTEL_SHUTDOWN_TIMEOUT", "time allowed to flush telemetry on shutdown", &c.Telemetry.ShutdownTimeout),
+		boolField("otel.insecure", "OTEL_EXPORTER_OTLP_INSECURE", "disable TLS for an endpoint without a scheme", &c.Telemetry.Insecure),
+		stringField("otel.certificate", "OTEL_EXPORTER_OTLP_CERTIFICATE", "PEM CA bundle to verify the collector", &c.Telemetry.Certificate),
+		headersField("otel.headers", "OTEL_EXPORTER_OTLP_HEADERS", "OTLP headers as key=value pairs separated by commas", &c.Telemetry.Headers),
+		stringField("otel.headers-file", "OTEL_EXPORTER_OTLP_HEADERS_FILE", "file containing the OTLP headers", &c.Telemetry.HeadersFile),
+		stringField("otel.traces-exporter", "OTEL_TRACES_EXPORTER", "traces exporter: otlp, stdout, file or none", &c.Telemetry.TracesExporter),
+		stringField("otel.traces-file", "OTEL_TRACES_FILE", "file for the file traces exporter", &c.Telemetry.TracesFile),
+		stringField("otel.traces-sampler", "OTEL_TRACES_SAMPLER", "sampler, e.g. parentbased_traceidratio", &c.Telemetry.TracesSampler),
+		floatField("otel.traces-sampler-arg", "OTEL_TRACES_SAMPLER_ARG", "sampling ratio for traceidratio samplers", &c.Telemetry.TracesSamplerArg),
+		stringField("otel.metrics-exporter", "OTEL_METRICS_EXPORTER", "metrics exporter: otlp, prometheus, both or none", &c.Telemetry.MetricsExporter),
+		stringField("otel.logs-exporter", "OTEL_LOGS_EXPORTER", "logs exporter: otlp or none", &c.Telemetry.LogsExporter),
+		stringField("otel.pii-redaction", "PII_REDACTION", "how IDNs appear in spans and logs: mask, hash or none", &c.Telemetry.PIIRedaction),
+		stringField("otel.pii-hash-key", "PII_HASH_KEY", "secret key for hashed IDNs", &c.Telemetry.PIIHashKey),
+		stringField("otel.pii-hash-key-file", "PII_HASH_KEY_FILE", "file containing the key for hashed IDNs", &c.Telemetry.PIIHashKeyFile),
+		stringField("admin.port", "ADMIN_PORT", "internal HTTP port serving /metrics and /admin", &c.Admin.Port),
+		stringField("log.format", "LOG_FORMAT", "log output format: json or text", &c.Log.Format),
+		stringField("log.level", "LOG_LEVEL", "log level: debug, info, warn, error", &c.Runtime.LogLevel),
+		floatField("rate-limit.rps", "RATE_LIMIT_RPS", "allowed API requests per second, 0 disables the limit", &c.Runtime.RateLimit.RPS),
+		intField("rate-limit.burst", "RATE_LIMIT_BURST", "rate limit burst size", &c.Runtime.RateLimit.Burst),
+	}
+
+	switch c.service {
+	case CustomerService:
+		fields = append(fields,
+			boolField("grpc.reflection", "GRPC_REFLECTION", "register gRPC server reflection", &c.GRPC.Reflection),
+		)
+	case ShipmentService:
+		fields = append(fields,
+			stringField("customer.endpoint", "GRPC_ENVOY_ENDPOINT", "customer-service gRPC endpoint, or memory for an in-memory stand-in", &c.Customer.Endpoint),
+			durationField("customer.sync-interval", "CUSTOMER_SYNC_INTERVAL", "customer event feed poll interval, 0 disables", &c.Customer.SyncInterval),
+			durationField("shutdown.drain-delay", "SHUTDOWN_DRAIN_DELAY", "how long readiness fails before shutdown", &c.Shutdown.DrainDelay),
+			intField("retry.max-attempts", "RETRY_MAX_ATTEMPTS", "attempts per customer-service call", &c.Runtime.Retry.MaxAttempts),
+			durationField("retry.initial-backoff", "RETRY_INITIAL_BACKOFF", "backoff before the first retry", &c.Runtime.Retry.InitialBackoff),
+			durationField("retry.max-backoff", "RETRY_MAX_BACKOFF", "upper bound for retry backoff", &c.Runtime.Retry.MaxBackoff),
+			stringField("auth.jwks-file", "AUTH_JWKS_FILE", "JWKS file with token signing keys", &c.Auth.JWKSFile),
+			boolField("auth.disabled", "AUTH_DISABLED", "serve the API without authentication", &c.Auth.Disabled),
+			durationField("auth.jwks-reload-interval", "AUTH_JWKS_RELOAD_INTERVAL", "how often the JWKS file is checked for changes", &c.Auth.JWKSReloadInterval),
+			stringField("auth.issuer", "AUTH_ISSUER", "required token iss", &c.Auth.Issuer),
+			stringField("auth.audience", "AUTH_AUDIENCE", "required token aud", &c.Auth.Audience),
+			stringField("auth.read-scope", "AUTH_READ_SCOPE", "scope required for GET requests", &c.Auth.ReadScope),
+			stringField("auth.write-scope", "AUTH_WRITE_SCOPE", "scope required for other requests", &c.Auth.WriteScope),
+		)
+	}
+
+	return fields
+}
+
+func stringField(name, env, usage string, p *string) field {
+	return field{flag: name, env: env, usage: usage, set: func(v string) error {
+		*p = v
+		return nil
+	}}
+}
+
+func boolField(name, env, usage string, p *bool) field {
+	return field{flag: name, env: env, usage: usage, set: func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*p = b
+		return nil
+	}}
+}
+
+func durationField(name, env, usage string, p *time.Duration) field {
+	return field{flag: name, env: env, usage: usage, set: func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*p = d
+		return nil
+	}}
+}
+
+func intField(name, env, usage string, p *int) field {
+	return field{flag: name, env: env, usage: usage, set: func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*p = n
+		return nil
+	}}
+}
+
+func int32Field(name, env, usage string, p *int32) field {
+	return field{flag: name, env: env, usage: usage, set: func(v string) error {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return err
+		}
+		*p = int32(n)
+		return nil
+	}}
+}
+
+func floatField(name, env, usage string, p *float64) field {
+	return field{flag: name, env: env, usage: usage, set: func(v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*p = f
+		return nil
+	}}
+}
+
+// headersField parses "k1=v1,k2=v2" as in OTEL_EXPORTER_OTLP_HEADERS.
+func headersField(name, env, usage string, p *map[string]string) field {
+	return field{flag: name, env: env, usage: usage, set: func(v string) error {
+		headers, err := parseHeaders(v)
+		if err != nil {
+			return err
+		}
+		*p = headers
+		return nil
+	}}
+}
+
+// parseHeaders parses "k1=v1,k2=v2"; values may be URL-encoded. Pairs may
+// also be separated by newlines, which is handier in a secrets file.
+func parseHeaders(v string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '\n' }) {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("header %q: want key=value", pair)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", key, err)
+		}
+		headers[strings.TrimSpace(key)] = decoded
+	}
+	return headers, nil
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Snapshot is one version of the runtime configuration. Snapshots are
+// shared between goroutines and must not be modified.
+type Snapshot struct {
+	Version  uint64        `json:"version"`
+	LoadedAt time.Time     `json:"loaded_at"`
+	Runtime  RuntimeConfig `json:"runtime"`
+}
+
+// Reloader re-reads the configuration on SIGHUP or when the config file
+// changes and hands each new RuntimeConfig to its subscribers. Settings
+// outside Runtime still need a restart.
+type Reloader struct {
+	cfg  *Config
+	args []string
+	// last is the most recently loaded configuration, so a setting that
+	// needs a restart is reported once per change rather than on every
+	// reload.
+	last *Config
+
+	current atomic.Pointer[Snapshot]
+
+	mu          sync.Mutex
+	subscribers []func(*Snapshot)
+	fileModTime time.Time
+
+	hup chan os.Signal
+}
+
+// NewReloader starts at version 1 with the runtime settings of cfg. args
+// must be the arguments cfg was loaded from so flags keep their precedence
+// on reload.
+//
+// SIGHUP is caught from here on, so a signal that arrives before Run starts
+// is queued instead of killing the process.
+func NewReloader(cfg *Config, args []string) *Reloader {
+	r := &Reloader{cfg: cfg, args: args, last: cfg, hup: make(chan os.Signal, 1)}
+	signal.Notify(r.hup, syscall.SIGHUP)
+	r.current.Store(newSnapshot(1, cfg.Runtime))
+	r.fileModTime = r.statFile()
+	return r
+}
+
+func newSnapshot(version uint64, rt RuntimeConfig) *Snapshot {
+	return &Snapshot{Version: version, LoadedAt: time.Now(), Runtime: rt}
+}
+
+// Current returns the active snapshot.
+func (r *Reloader) Current() *Snapshot {
+	return r.current.Load()
+}
+
+// Subscribe registers fn and calls it right away with the current snapshot.
+// fn is called again, from the reloading goroutine, for every new version.
+func (r *Reloader) Subscribe(fn func(*Snapshot)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+	fn(r.current.Load())
+}
+
+// Reload loads the configuration again and publishes a new snapshot if the
+// runtime settings changed. An invalid configuration is rejected and the
+// current snapshot stays active.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fileModTime = r.statFile()
+
+	cfg, _, err := load(r.cfg.service, r.args)
+	if err != nil {
+		return err
+	}
+
+	if !staticEqual(r.last, cfg) {
+		slog.Warn("Config reload: settings outside runtime changed and will apply after a restart")
+	}
+	r.last = cfg
+
+	prev := r.current.Load()
+	if reflect.DeepEqual(prev.Runtime, cfg.Runtime) {
+		return nil
+	}
+
+	next := newSnapshot(prev.Version+1, cfg.Runtime)
+	r.current.Store(next)
+	for _, fn := range r.subscribers {
+		fn(next)
+	}
+
+	slog.Info("Config reloaded", "version", next.Version)
+	return nil
+}
+
+func staticEqual(x, y *Config) bool {
+	a, b := *x, *y
+	a.Runtime, b.Runtime = RuntimeConfig{}, RuntimeConfig{}
+	return reflect.DeepEqual(a, b)
+}
+
+func (r *Reloader) statFile() time.Time {
+	if r.cfg.file == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(r.cfg.file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Run reloads on SIGHUP and, when a config file is used, whenever its
+// modification time changes. It blocks until ctx is done.
+func (r *Reloader) Run(ctx context.Context, pollInterval time.Duration) {
+	defer signal.Stop(r.hup)
+
+	var poll <-chan time.Time
+	if r.cfg.file != "" && pollInterval > 0 {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.hup:
+			slog.Info("Received SIGHUP, reloading config")
+		case <-poll:
+			r.mu.Lock()
+			changed := !r.statFile().Equal(r.fileModTime)
+			r.mu.Unlock()
+			if !changed {
+				continue
+			}
+			slog.Info("Config file changed, reloading", "file", r.cfg.file)
+		}
+
+		if err := r.Reload(); err != nil {
+			slog.Error("Config reload failed, keeping current version", "version", r.Current().Version, "error", err)
+		}
+	}
+}
+
+// ServeHTTP reports the active runtime configuration and its version.
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.Current())
+}
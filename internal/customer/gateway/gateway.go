@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	pb "testovoe/api/proto"
+	"testovoe/internal/metrics"
+	"testovoe/internal/reqctx"
+)
+
+// NewHandler returns an HTTP handler that translates REST calls into direct
+// calls on srv. Requests do not leave the process, so the handler is wrapped
+// with otelhttp to extract the incoming trace context and start the server
+// span the gRPC methods attach to.
+func NewHandler(ctx context.Context, srv pb.CustomerServiceServer) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+		runtime.WithMiddlewares(recordMetrics, reqctx.GatewayMiddleware),
+	)
+
+	if err := pb.RegisterCustomerServiceHandlerServer(ctx, mux, srv); err != nil {
+		return nil, fmt.Errorf("failed to register customer gateway: %w", err)
+	}
+
+	return otelhttp.NewHandler(mux, "customer-gateway",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	), nil
+}
+
+// recordMetrics records each request under the path pattern it matched,
+// which the gateway mux puts into the context before calling middleware.
+// Single-segment captures are written {idn} rather than {idn=*} to match
+// the route labels of shipment-service.
+func recordMetrics(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		start := time.Now()
+		sw, status := metrics.StatusWriter(w)
+		next(sw, r, pathParams)
+
+		route := "unknown"
+		if pattern, ok := runtime.HTTPPattern(r.Context()); ok {
+			route = strings.ReplaceAll(pattern.String(), "=*}", "}")
+		}
+		metrics.RecordHTTP(r.Context(), r.Method, route, status(), time.Since(start))
+	}
+}
+
+// NewGatewayServer builds the HTTP server for the REST gateway. The caller
+// starts it with ListenAndServe and stops it with Shutdown.
+func NewGatewayServer(port string, srv pb.CustomerServiceServer) (*http.Server, error) {
+	handler, err := NewHandler(context.Background(), srv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Server{
+		Addr:    ":" + port,
+		Handler: handler,
+	}, nil
+}
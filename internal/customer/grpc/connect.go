@@ -0,0 +1,89 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	pb "testovoe/api/proto"
+	"testovoe/api/proto/protoconnect"
+	"testovoe/internal/metrics"
+	"testovoe/internal/reqctx"
+)
+
+// ConnectHandler exposes Server over the Connect and gRPC-Web protocols so
+// browser clients can call it without a REST translation layer. The
+// internal ListCustomerEvents feed is left to the embedded Unimplemented
+// handler: it is served over classic gRPC only.
+type ConnectHandler struct {
+	protoconnect.UnimplementedCustomerServiceHandler
+	server *Server
+}
+
+func NewConnectHandler(server *Server) *ConnectHandler {
+	return &ConnectHandler{server: server}
+}
+
+func (h *ConnectHandler) UpsertCustomer(ctx context.Context, req *connect.Request[pb.UpsertCustomerRequest]) (*connect.Response[pb.CustomerResponse], error) {
+	resp, err := h.server.UpsertCustomer(ctx, req.Msg)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (h *ConnectHandler) GetCustomer(ctx context.Context, req *connect.Request[pb.GetCustomerRequest]) (*connect.Response[pb.CustomerResponse], error) {
+	resp, err := h.server.GetCustomer(ctx, req.Msg)
+	if err != nil {
+		return nil, toConnectError(err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// toConnectError carries the gRPC status code returned by Server over to
+// the Connect error so both protocols report the same code.
+func toConnectError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return connect.NewError(connect.CodeUnknown, err)
+	}
+	return connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+}
+
+// newMultiProtocolHandler serves classic gRPC through grpcServer and
+// everything else (Connect, gRPC-Web) through the Connect handler, so all
+// three protocols share one port.
+func newMultiProtocolHandler(grpcServer *grpc.Server, server *Server) http.Handler {
+	mux := http.NewServeMux()
+	path, handler := protoconnect.NewCustomerServiceHandler(NewConnectHandler(server),
+		connect.WithInterceptors(metrics.ConnectInterceptor(), reqctx.ConnectInterceptor()),
+	)
+	mux.Handle(path, handler)
+
+	connectHandler := otelhttp.NewHandler(mux, "customer-connect",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.URL.Path
+		}),
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isClassicGRPC(r) {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		connectHandler.ServeHTTP(w, r)
+	})
+}
+
+func isClassicGRPC(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return r.ProtoMajor == 2 &&
+		strings.HasPrefix(contentType, "application/grpc") &&
+		!strings.HasPrefix(contentType, "application/grpc-web")
+}
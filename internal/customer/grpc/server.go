@@ -2,51 +2,73 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 
 	pb "testovoe/api/proto"
+	"testovoe/internal/customer/repo"
 	"testovoe/internal/customer/service"
+	healthprobe "testovoe/internal/health"
+	"testovoe/internal/metrics"
+	"testovoe/internal/ratelimit"
+	"testovoe/internal/redact"
+	"testovoe/internal/reqctx"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
 )
 
 type Server struct {
 	pb.UnimplementedCustomerServiceServer
 	service *service.Service
+	limiter *ratelimit.Limiter
 }
 
-func NewServer(svc *service.Service) *Server {
-	return &Server{service: svc}
+// NewServer returns the CustomerService implementation. The limit is
+// checked in the methods themselves so it applies to gRPC, Connect and the
+// REST gateway alike; limiter may be nil.
+func NewServer(svc *service.Service, limiter *ratelimit.Limiter) *Server {
+	return &Server{service: svc, limiter: limiter}
 }
 
+var errRateLimited = status.Error(codes.ResourceExhausted, "rate limit exceeded")
+
 func (s *Server) UpsertCustomer(ctx context.Context, req *pb.UpsertCustomerRequest) (*pb.CustomerResponse, error) {
+	if !s.limiter.Allow() {
+		return nil, errRateLimited
+	}
+
 	ctx, span := otel.Tracer("customer-grpc").Start(ctx, "UpsertCustomer")
 	defer span.End()
 
 	span.SetAttributes(
 		attribute.String("grpc.method", "UpsertCustomer"),
-		attribute.String("customer.idn", req.Idn),
+		attribute.String("customer.idn", redact.IDN(req.Idn)),
 	)
 
 	c, err := s.service.UpsertCustomer(ctx, req.Idn)
 	if err != nil {
 		span.RecordError(err)
+		if errors.Is(err, service.ErrInvalidIDN) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		return nil, status.Errorf(codes.Internal, "failed to upsert customer: %v", err)
 	}
 
-	if spanCtx := span.SpanContext(); spanCtx.IsValid() {
-		traceID := spanCtx.TraceID().String()
-		log.Printf("Upserted customer %s (idn: %s), trace_id: %s", c.ID, c.IDN, traceID)
-	}
+	slog.InfoContext(ctx, "Upserted customer", "customer.id", c.ID, "customer.idn", redact.IDN(c.IDN))
 
 	return &pb.CustomerResponse{
 		Id:        c.ID,
@@ -56,27 +78,31 @@ func (s *Server) UpsertCustomer(ctx context.Context, req *pb.UpsertCustomerReque
 }
 
 func (s *Server) GetCustomer(ctx context.Context, req *pb.GetCustomerRequest) (*pb.CustomerResponse, error) {
+	if !s.limiter.Allow() {
+		return nil, errRateLimited
+	}
+
 	ctx, span := otel.Tracer("customer-grpc").Start(ctx, "GetCustomer")
 	defer span.End()
 
 	span.SetAttributes(
 		attribute.String("grpc.method", "GetCustomer"),
-		attribute.String("customer.idn", req.Idn),
+		attribute.String("customer.idn", redact.IDN(req.Idn)),
 	)
 
 	c, err := s.service.GetCustomer(ctx, req.Idn)
 	if err != nil {
 		span.RecordError(err)
-		if err.Error() == "customer not found" {
+		if errors.Is(err, service.ErrInvalidIDN) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, repo.ErrNotFound) {
 			return nil, status.Errorf(codes.NotFound, "customer not found")
 		}
 		return nil, status.Errorf(codes.Internal, "failed to get customer: %v", err)
 	}
 
-	if spanCtx := span.SpanContext(); spanCtx.IsValid() {
-		traceID := spanCtx.TraceID().String()
-		log.Printf("Retrieved customer %s (idn: %s), trace_id: %s", c.ID, c.IDN, traceID)
-	}
+	slog.InfoContext(ctx, "Retrieved customer", "customer.id", c.ID, "customer.idn", redact.IDN(c.IDN))
 
 	return &pb.CustomerResponse{
 		Id:        c.ID,
@@ -85,18 +111,160 @@ func (s *Server) GetCustomer(ctx context.Context, req *pb.GetCustomerRequest) (*
 	}, nil
 }
 
-func StartGRPCServer(port string, svc *service.Service) error {
-	lis, err := net.Listen("tcp", ":"+port)
+func (s *Server) ListCustomerEvents(ctx context.Context, req *pb.ListCustomerEventsRequest) (*pb.ListCustomerEventsResponse, error) {
+	if !s.limiter.Allow() {
+		return nil, errRateLimited
+	}
+
+	ctx, span := otel.Tracer("customer-grpc").Start(ctx, "ListCustomerEvents")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("grpc.method", "ListCustomerEvents"),
+		attribute.Int64("customer.events.after_sequence", req.AfterSequence),
+	)
+
+	events, err := s.service.ListEvents(ctx, req.AfterSequence, int(req.Limit))
+	if err != nil {
+		span.RecordError(err)
+		return nil, status.Errorf(codes.Internal, "failed to list customer events: %v", err)
+	}
+
+	span.SetAttributes(attribute.Int("customer.events.count", len(events)))
+
+	resp := &pb.ListCustomerEventsResponse{Events: make([]*pb.CustomerEvent, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, &pb.CustomerEvent{
+			Sequence: e.Sequence,
+			Type:     e.Type,
+			Customer: &pb.CustomerResponse{
+				Id:        e.Customer.ID,
+				Idn:       e.Customer.IDN,
+				CreatedAt: e.Customer.CreatedAt.Format(time.RFC3339),
+			},
+			OccurredAt: e.OccurredAt.Format(time.RFC3339),
+		})
+	}
+
+	return resp, nil
+}
+
+const defaultDrainTimeout = 10 * time.Second
+
+type Options struct {
+	Port string
+	// Reflection registers the server reflection service so tools like
+	// grpcurl can discover the API.
+	Reflection bool
+	// HealthInterval is how often the database is pinged to update the
+	// grpc.health.v1 status.
+	HealthInterval time.Duration
+	// DrainTimeout bounds how long GracefulStop waits for in-flight RPCs
+	// before closing the remaining connections.
+	DrainTimeout time.Duration
+	// RateLimiter, if set, limits calls across all protocols.
+	RateLimiter *ratelimit.Limiter
+}
+
+// GRPCServer is a running customer gRPC server. It is returned by
+// StartGRPCServer so the caller can stop it.
+type GRPCServer struct {
+	grpcServer   *grpc.Server
+	httpServer   *http.Server
+	health       *health.Server
+	stopProbe    context.CancelFunc
+	drainTimeout time.Duration
+	errCh        chan error
+}
+
+// StartGRPCServer listens on opts.Port and serves gRPC, gRPC-Web and Connect
+// in the background. Serve errors are delivered on Err.
+func StartGRPCServer(ctx context.Context, opts Options, svc *service.Service, db healthprobe.Pinger) (*GRPCServer, error) {
+	lis, err := net.Listen("tcp", ":"+opts.Port)
 	if err != nil {
-		return fmt.Errorf("failed to listen: %w", err)
+		return nil, fmt.Errorf("failed to listen: %w", err)
 	}
 
 	s := grpc.NewServer(
-		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
+		grpc.ChainUnaryInterceptor(
+			// RED metrics come from metrics.UnaryServerInterceptor, which
+			// labels them the same way as the Connect and HTTP ones.
+			otelgrpc.UnaryServerInterceptor(otelgrpc.WithMeterProvider(noop.NewMeterProvider())),
+			metrics.UnaryServerInterceptor(),
+			reqctx.UnaryServerInterceptor(),
+		),
 	)
 
-	pb.RegisterCustomerServiceServer(s, NewServer(svc))
+	server := NewServer(svc, opts.RateLimiter)
+	pb.RegisterCustomerServiceServer(s, server)
+
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+
+	probeCtx, stopProbe := context.WithCancel(ctx)
+	probe := healthprobe.NewProbe(hs, opts.HealthInterval, pb.CustomerService_ServiceDesc.ServiceName)
+	probe.AddCheck("database", db.Ping)
+	probe.Run(probeCtx)
+
+	if opts.Reflection {
+		reflection.Register(s)
+	}
+
+	// HTTP/1.1 is needed for Connect and gRPC-Web from browsers, cleartext
+	// HTTP/2 for classic gRPC clients such as shipment-service.
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	drainTimeout := opts.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+
+	srv := &GRPCServer{
+		grpcServer: s,
+		httpServer: &http.Server{
+			Handler:   newMultiProtocolHandler(s, server),
+			Protocols: protocols,
+		},
+		health:       hs,
+		stopProbe:    stopProbe,
+		drainTimeout: drainTimeout,
+		errCh:        make(chan error, 1),
+	}
+
+	go func() {
+		slog.Info("Customer gRPC server listening (gRPC, gRPC-Web, Connect)", "port", opts.Port)
+		if err := srv.httpServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			srv.errCh <- err
+		}
+		close(srv.errCh)
+	}()
+
+	return srv, nil
+}
+
+// Err returns a channel that receives the error that made the server stop
+// serving unexpectedly. It is closed once serving ends.
+func (s *GRPCServer) Err() <-chan error {
+	return s.errCh
+}
 
-	log.Printf("Customer gRPC server listening on :%s", port)
-	return s.Serve(lis)
+// GracefulStop marks the server NOT_SERVING, stops accepting connections and
+// waits up to the drain timeout for in-flight RPCs. Connections still open
+// after that are closed forcibly.
+func (s *GRPCServer) GracefulStop() {
+	s.stopProbe()
+	s.health.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+
+	// grpc.Server.GracefulStop is not supported for ServeHTTP transports, so
+	// draining is done by the HTTP server that owns the connections.
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		slog.Warn("gRPC server did not drain in time, closing connections", "timeout", s.drainTimeout.String(), "error", err)
+		s.httpServer.Close()
+	}
+	s.grpcServer.Stop()
 }
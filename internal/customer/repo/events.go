@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"testovoe/internal/audit"
+)
+
+const EventCustomerCreated = "CUSTOMER_CREATED"
+
+// eventsLockKey is the transaction-level advisory lock taken while writing
+// to customer_events.
+const eventsLockKey = 0x637573745f657674 // "cust_evt"
+
+// Event is one entry of the customer_events feed.
+type Event struct {
+	Sequence   int64
+	Type       string
+	Customer   Customer
+	OccurredAt time.Time
+}
+
+// newCreatedEntry returns the audit entry for the creation of c.
+func newCreatedEntry(ctx context.Context, c *Customer) (*audit.Entry, error) {
+	return audit.NewEntry(ctx, audit.ActionCreate, audit.EntityCustomer, c.ID, nil, c)
+}
+
+func insertEvent(ctx context.Context, tx pgx.Tx, eventType string, c *Customer) error {
+	query := `INSERT INTO customer_events (type, customer_id, idn, customer_created_at)
+		VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(ctx, query, eventType, c.ID, c.IDN, c.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert customer event: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns up to limit events with a sequence greater than after,
+// oldest first.
+func (r *Repository) ListEvents(ctx context.Context, after int64, limit int) ([]*Event, error) {
+	query := `SELECT sequence, type, customer_id, idn, customer_created_at, occurred_at
+		FROM customer_events
+		WHERE sequence > $1
+		ORDER BY sequence
+		LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(
+			&e.Sequence,
+			&e.Type,
+			&e.Customer.ID,
+			&e.Customer.IDN,
+			&e.Customer.CreatedAt,
+			&e.OccurredAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan customer event: %w", err)
+		}
+		events = append(events, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate customer events: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"testovoe/internal/audit"
+)
+
+// MemoryRepository keeps customers in memory. It is safe for concurrent use
+// and meant for demos and tests; data is lost on restart.
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	byIDN  map[string]*Customer
+	byID   map[string]*Customer
+	events []*Event
+	audit  audit.MemoryLog
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		byIDN: make(map[string]*Customer),
+		byID:  make(map[string]*Customer),
+	}
+}
+
+func (r *MemoryRepository) UpsertCustomer(ctx context.Context, idn string) (*Customer, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.byIDN[idn]; ok {
+		customer := *c
+		return &customer, false, nil
+	}
+
+	c := &Customer{
+		ID:        uuid.New().String(),
+		IDN:       idn,
+		CreatedAt: time.Now(),
+	}
+	entry, err := newCreatedEntry(ctx, c)
+	if err != nil {
+		return nil, false, err
+	}
+
+	r.byIDN[idn] = c
+	r.byID[c.ID] = c
+	r.events = append(r.events, &Event{
+		Sequence:   int64(len(r.events) + 1),
+		Type:       EventCustomerCreated,
+		Customer:   *c,
+		OccurredAt: c.CreatedAt,
+	})
+	r.audit.Append(entry)
+
+	customer := *c
+	return &customer, true, nil
+}
+
+func (r *MemoryRepository) GetCustomer(ctx context.Context, idn string) (*Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.byIDN[idn]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	customer := *c
+	return &customer, nil
+}
+
+func (r *MemoryRepository) GetCustomerByID(ctx context.Context, id string) (*Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.byID[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	customer := *c
+	return &customer, nil
+}
+
+func (r *MemoryRepository) ListEvents(ctx context.Context, after int64, limit int) ([]*Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Sequences start at 1 and have no gaps, so after is also the index of
+	// the first event to return.
+	if after < 0 {
+		after = 0
+	}
+	var events []*Event
+	for i := after; i < int64(len(r.events)) && len(events) < limit; i++ {
+		e := *r.events[i]
+		events = append(events, &e)
+	}
+	return events, nil
+}
+
+func (r *MemoryRepository) ListAudit(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error) {
+	return r.audit.List(filter), nil
+}
+
+// Ping always succeeds; it lets the repository stand in for the database
+// in health checks.
+func (r *MemoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
@@ -2,136 +2,146 @@ package repo
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"testovoe/internal/audit"
 )
 
+var ErrNotFound = errors.New("customer not found")
+
 type Customer struct {
-	ID        string
-	IDN       string
-	CreatedAt time.Time
+	ID        string    `json:"id"`
+	IDN       string    `json:"idn"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Repository struct {
-	db *sql.DB
+	db *pgxpool.Pool
 }
 
-func NewRepository(db *sql.DB) *Repository {
+func NewRepository(db *pgxpool.Pool) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) UpsertCustomer(ctx context.Context, idn string) (*Customer, error) {
-	ctx, span := otel.Tracer("customer-repo").Start(ctx, "UpsertCustomer")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("db.operation", "upsert"),
-		attribute.String("customer.idn", idn),
-	)
-
+// UpsertCustomer returns the customer with idn, creating it if needed. The
+// flag reports whether this call created it.
+func (r *Repository) UpsertCustomer(ctx context.Context, idn string) (*Customer, bool, error) {
 	var customer Customer
 	query := `SELECT id, idn, created_at FROM customers WHERE idn = $1`
-	err := r.db.QueryRowContext(ctx, query, idn).Scan(
+	err := r.db.QueryRow(ctx, query, idn).Scan(
 		&customer.ID,
 		&customer.IDN,
 		&customer.CreatedAt,
 	)
 
 	if err == nil {
-		span.SetAttributes(attribute.String("db.result", "found"))
-		return &customer, nil
+		return &customer, false, nil
 	}
 
-	if err != sql.ErrNoRows {
-		span.RecordError(err)
-		return nil, fmt.Errorf("failed to query customer: %w", err)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to query customer: %w", err)
 	}
 
-	span.SetAttributes(attribute.String("db.result", "created"))
 	customer.ID = uuid.New().String()
 	customer.IDN = idn
 	customer.CreatedAt = time.Now()
 
-	insertQuery := `INSERT INTO customers (id, idn, created_at) VALUES ($1, $2, $3) 
-		ON CONFLICT (idn) DO UPDATE SET idn = EXCLUDED.idn 
-		RETURNING id, idn, created_at`
+	entry, err := newCreatedEntry(ctx, &customer)
+	if err != nil {
+		return nil, false, err
+	}
 
-	err = r.db.QueryRowContext(ctx, insertQuery, customer.ID, customer.IDN, customer.CreatedAt).Scan(
-		&customer.ID,
-		&customer.IDN,
-		&customer.CreatedAt,
-	)
+	created := false
+	err = pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		// Serialise creations so customer_events sequences commit in order;
+		// otherwise a reader could see sequence N+1 before N and skip N.
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, eventsLockKey); err != nil {
+			return err
+		}
+
+		insertQuery := `INSERT INTO customers (id, idn, created_at) VALUES ($1, $2, $3)
+			ON CONFLICT (idn) DO NOTHING
+			RETURNING id, idn, created_at`
+
+		err := tx.QueryRow(ctx, insertQuery, customer.ID, customer.IDN, customer.CreatedAt).Scan(
+			&customer.ID,
+			&customer.IDN,
+			&customer.CreatedAt,
+		)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Created concurrently; the other transaction wrote the event.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
 
+		created = true
+		if err := insertEvent(ctx, tx, EventCustomerCreated, &customer); err != nil {
+			return err
+		}
+		return audit.AppendPostgres(ctx, tx, entry)
+	})
 	if err != nil {
-		span.RecordError(err)
-		return nil, fmt.Errorf("failed to insert customer: %w", err)
+		return nil, false, fmt.Errorf("failed to insert customer: %w", err)
 	}
 
-	return &customer, nil
+	if !created {
+		c, err := r.GetCustomer(ctx, idn)
+		return c, false, err
+	}
+
+	return &customer, true, nil
 }
 
 func (r *Repository) GetCustomer(ctx context.Context, idn string) (*Customer, error) {
-	ctx, span := otel.Tracer("customer-repo").Start(ctx, "GetCustomer")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("db.operation", "select"),
-		attribute.String("customer.idn", idn),
-	)
-
 	var customer Customer
 	query := `SELECT id, idn, created_at FROM customers WHERE idn = $1`
-	err := r.db.QueryRowContext(ctx, query, idn).Scan(
+	err := r.db.QueryRow(ctx, query, idn).Scan(
 		&customer.ID,
 		&customer.IDN,
 		&customer.CreatedAt,
 	)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			span.SetAttributes(attribute.String("db.result", "not_found"))
-			return nil, fmt.Errorf("customer not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
 		}
-		span.RecordError(err)
 		return nil, fmt.Errorf("failed to query customer: %w", err)
 	}
 
-	span.SetAttributes(attribute.String("db.result", "found"))
 	return &customer, nil
 }
 
 func (r *Repository) GetCustomerByID(ctx context.Context, id string) (*Customer, error) {
-	ctx, span := otel.Tracer("customer-repo").Start(ctx, "GetCustomerByID")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("db.operation", "select"),
-		attribute.String("customer.id", id),
-	)
-
 	var customer Customer
 	query := `SELECT id, idn, created_at FROM customers WHERE id = $1`
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := r.db.QueryRow(ctx, query, id).Scan(
 		&customer.ID,
 		&customer.IDN,
 		&customer.CreatedAt,
 	)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			span.SetAttributes(attribute.String("db.result", "not_found"))
-			return nil, fmt.Errorf("customer not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
 		}
-		span.RecordError(err)
 		return nil, fmt.Errorf("failed to query customer: %w", err)
 	}
 
-	span.SetAttributes(attribute.String("db.result", "found"))
 	return &customer, nil
 }
+
+func (r *Repository) ListAudit(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error) {
+	return audit.ListPostgres(ctx, r.db, filter)
+}
+
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.Ping(ctx)
+}
@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"testovoe/internal/audit"
+)
+
+// SQLiteRepository stores customers in a SQLite database. It is meant for
+// local development; see database.OpenSQLite.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+func (r *SQLiteRepository) UpsertCustomer(ctx context.Context, idn string) (*Customer, bool, error) {
+	customer, err := r.GetCustomer(ctx, idn)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return customer, false, err
+	}
+
+	customer = &Customer{
+		ID:        uuid.New().String(),
+		IDN:       idn,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	entry, err := newCreatedEntry(ctx, customer)
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to insert customer: %w", err)
+	}
+	defer tx.Rollback()
+
+	insertQuery := `INSERT INTO customers (id, idn, created_at) VALUES (?1, ?2, ?3)
+		ON CONFLICT (idn) DO NOTHING`
+	res, err := tx.ExecContext(ctx, insertQuery, customer.ID, customer.IDN, customer.CreatedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to insert customer: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Created concurrently; the other transaction wrote the event.
+		tx.Rollback()
+		customer, err := r.GetCustomer(ctx, idn)
+		return customer, false, err
+	}
+
+	eventQuery := `INSERT INTO customer_events (type, customer_id, idn, customer_created_at, occurred_at)
+		VALUES (?1, ?2, ?3, ?4, ?4)`
+	if _, err := tx.ExecContext(ctx, eventQuery, EventCustomerCreated, customer.ID, customer.IDN, customer.CreatedAt); err != nil {
+		return nil, false, fmt.Errorf("failed to insert customer event: %w", err)
+	}
+
+	if err := audit.AppendSQLite(ctx, tx, entry); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to insert customer: %w", err)
+	}
+
+	return customer, true, nil
+}
+
+func (r *SQLiteRepository) GetCustomer(ctx context.Context, idn string) (*Customer, error) {
+	query := `SELECT id, idn, created_at FROM customers WHERE idn = ?1`
+	return r.getCustomer(ctx, query, idn)
+}
+
+func (r *SQLiteRepository) GetCustomerByID(ctx context.Context, id string) (*Customer, error) {
+	query := `SELECT id, idn, created_at FROM customers WHERE id = ?1`
+	return r.getCustomer(ctx, query, id)
+}
+
+func (r *SQLiteRepository) getCustomer(ctx context.Context, query string, arg string) (*Customer, error) {
+	var customer Customer
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(
+		&customer.ID,
+		&customer.IDN,
+		&customer.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query customer: %w", err)
+	}
+
+	return &customer, nil
+}
+
+func (r *SQLiteRepository) ListEvents(ctx context.Context, after int64, limit int) ([]*Event, error) {
+	query := `SELECT sequence, type, customer_id, idn, customer_created_at, occurred_at
+		FROM customer_events
+		WHERE sequence > ?1
+		ORDER BY sequence
+		LIMIT ?2`
+
+	rows, err := r.db.QueryContext(ctx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(
+			&e.Sequence,
+			&e.Type,
+			&e.Customer.ID,
+			&e.Customer.IDN,
+			&e.Customer.CreatedAt,
+			&e.OccurredAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan customer event: %w", err)
+		}
+		events = append(events, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate customer events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (r *SQLiteRepository) ListAudit(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error) {
+	return audit.ListSQLite(ctx, r.db, filter)
+}
+
+func (r *SQLiteRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
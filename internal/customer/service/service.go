@@ -2,25 +2,56 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 
 	"testovoe/internal/customer/repo"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
+// Repository stores customers. repo.Repository (Postgres) and
+// repo.MemoryRepository implement it.
+type Repository interface {
+	// UpsertCustomer also reports whether the customer was created.
+	UpsertCustomer(ctx context.Context, idn string) (*repo.Customer, bool, error)
+	GetCustomer(ctx context.Context, idn string) (*repo.Customer, error)
+	GetCustomerByID(ctx context.Context, id string) (*repo.Customer, error)
+	ListEvents(ctx context.Context, after int64, limit int) ([]*repo.Event, error)
+}
+
 type Service struct {
-	repo *repo.Repository
+	repo    Repository
+	upserts metric.Int64Counter
 }
 
-func NewService(repo *repo.Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo Repository) *Service {
+	meter := otel.Meter("customer-service")
+
+	// Instrument creation only fails on an invalid name.
+	upserts, _ := meter.Int64Counter("customers.upserts",
+		metric.WithDescription("Customer upserts by result: created or found"),
+		metric.WithUnit("{upsert}"),
+	)
+
+	return &Service{repo: repo, upserts: upserts}
 }
 
+const (
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
 var idnRegex = regexp.MustCompile(`^\d{12}$`)
 
+var ErrInvalidIDN = errors.New("idn must be exactly 12 digits")
+
 func (s *Service) ValidateIDN(idn string) error {
 	if !idnRegex.MatchString(idn) {
-		return fmt.Errorf("idn must be exactly 12 digits")
+		return ErrInvalidIDN
 	}
 	return nil
 }
@@ -30,11 +61,17 @@ func (s *Service) UpsertCustomer(ctx context.Context, idn string) (*repo.Custome
 		return nil, err
 	}
 
-	customer, err := s.repo.UpsertCustomer(ctx, idn)
+	customer, created, err := s.repo.UpsertCustomer(ctx, idn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upsert customer: %w", err)
 	}
 
+	result := "found"
+	if created {
+		result = "created"
+	}
+	s.upserts.Add(ctx, 1, metric.WithAttributes(attribute.String("customer.upsert.result", result)))
+
 	return customer, nil
 }
 
@@ -51,3 +88,21 @@ func (s *Service) GetCustomer(ctx context.Context, idn string) (*repo.Customer,
 	return customer, nil
 }
 
+
+// ListEvents returns the customer events after the given sequence. A limit
+// of 0 selects the default page size.
+func (s *Service) ListEvents(ctx context.Context, after int64, limit int) ([]*repo.Event, error) {
+	if limit <= 0 {
+		limit = defaultEventsLimit
+	}
+	if limit > maxEventsLimit {
+		limit = maxEventsLimit
+	}
+
+	events, err := s.repo.ListEvents(ctx, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list customer events: %w", err)
+	}
+
+	return events, nil
+}
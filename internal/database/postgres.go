@@ -0,0 +1,96 @@
+// Package database opens the database handles shared by the repositories
+// (a Postgres pool or a SQLite file) and reports pool statistics as metrics.
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"testovoe/internal/config"
+)
+
+// Open opens the pool with the configured limits, installs the tracing
+// hook and checks connectivity.
+func Open(ctx context.Context, cfg config.DBConfig) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
+	}
+
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+	poolCfg.MinConns = cfg.MinConns
+	poolCfg.MaxConnLifetime = cfg.ConnMaxLifetime
+	poolCfg.MaxConnIdleTime = cfg.ConnMaxIdleTime
+	poolCfg.ConnConfig.Tracer = NewTracer(cfg.Name)
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return pool, nil
+}
+
+// RegisterPoolMetrics exports pgxpool statistics through the global
+// MeterProvider using the OpenTelemetry db.client.connections.* names. pool
+// identifies the pool in the pool.name attribute.
+func RegisterPoolMetrics(db *pgxpool.Pool, pool string) error {
+	meter := otel.Meter("testovoe/internal/database")
+
+	usage, err := meter.Int64ObservableUpDownCounter("db.client.connections.usage",
+		metric.WithDescription("Number of connections that are currently in the state described by the state attribute."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+	maxConns, err := meter.Int64ObservableUpDownCounter("db.client.connections.max",
+		metric.WithDescription("Maximum number of open connections allowed."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+	waitCount, err := meter.Int64ObservableCounter("db.client.connections.wait_count",
+		metric.WithDescription("Total number of connections waited for."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+	waitTime, err := meter.Float64ObservableCounter("db.client.connections.wait_time",
+		metric.WithDescription("Total time blocked waiting for a new connection."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	poolAttr := attribute.String("pool.name", pool)
+	usedAttrs := metric.WithAttributes(poolAttr, attribute.String("state", "used"))
+	idleAttrs := metric.WithAttributes(poolAttr, attribute.String("state", "idle"))
+	poolAttrs := metric.WithAttributes(poolAttr)
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := db.Stat()
+		o.ObserveInt64(usage, int64(stats.AcquiredConns()), usedAttrs)
+		o.ObserveInt64(usage, int64(stats.IdleConns()), idleAttrs)
+		o.ObserveInt64(maxConns, int64(stats.MaxConns()), poolAttrs)
+		o.ObserveInt64(waitCount, stats.EmptyAcquireCount(), poolAttrs)
+		o.ObserveFloat64(waitTime, stats.EmptyAcquireWaitTime().Seconds(), poolAttrs)
+		return nil
+	}, usage, maxConns, waitCount, waitTime)
+	return err
+}
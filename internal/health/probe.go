@@ -0,0 +1,90 @@
+// Package health keeps the grpc.health.v1 status of a gRPC server in step
+// with the dependencies it needs, such as its database.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	defaultInterval = 5 * time.Second
+	checkTimeout    = 2 * time.Second
+)
+
+// Pinger is the part of a store the database check needs.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type check struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Probe runs its checks every interval and reports the result as the
+// serving status of the whole server and of each of its services: SERVING
+// when every check passes, NOT_SERVING otherwise.
+type Probe struct {
+	server   *health.Server
+	services []string
+	interval time.Duration
+	checks   []check
+}
+
+// NewProbe returns a probe updating server. A non-positive interval means
+// the default of 5s.
+func NewProbe(server *health.Server, interval time.Duration, services ...string) *Probe {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	return &Probe{server: server, services: services, interval: interval}
+}
+
+// AddCheck registers a check; it must be called before Run.
+func (p *Probe) AddCheck(name string, fn func(ctx context.Context) error) {
+	p.checks = append(p.checks, check{name: name, fn: fn})
+}
+
+// Run probes once before it returns, so the server never starts out
+// SERVING against an unreachable database, and then every interval until
+// ctx is done.
+func (p *Probe) Run(ctx context.Context) {
+	p.probe(ctx)
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.probe(ctx)
+			}
+		}
+	}()
+}
+
+func (p *Probe) probe(ctx context.Context) {
+	st := healthpb.HealthCheckResponse_SERVING
+	for _, c := range p.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := c.fn(checkCtx)
+		cancel()
+		if err != nil {
+			st = healthpb.HealthCheckResponse_NOT_SERVING
+			slog.WarnContext(ctx, "Health probe: check failed", "check", c.name, "error", err)
+		}
+	}
+
+	p.server.SetServingStatus("", st)
+	for _, service := range p.services {
+		p.server.SetServingStatus(service, st)
+	}
+}
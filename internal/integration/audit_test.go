@@ -0,0 +1,157 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"testovoe/internal/audit"
+	shipmentrepo "testovoe/internal/shipment/repo"
+)
+
+func TestMutationsAreAudited(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+
+	resp, shipment := h.createShipment(t, shipmentBody("ALMATY-ASTANA", randomIDN()))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST: got status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		store    audit.Store
+		entity   string
+		entityID string
+	}{
+		{"shipment", h.shipmentAudit, audit.EntityShipment, shipment.ID},
+		{"customer", h.customerAudit, audit.EntityCustomer, shipment.CustomerID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := tc.store.ListAudit(ctx, audit.Filter{
+				EntityType: tc.entity,
+				EntityID:   tc.entityID,
+				Limit:      10,
+			})
+			if err != nil {
+				t.Fatalf("ListAudit: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(entries))
+			}
+
+			e := entries[0]
+			if e.Action != audit.ActionCreate || e.Before != nil {
+				t.Errorf("got action %q before %s, want create with no before", e.Action, e.Before)
+			}
+			var after map[string]any
+			if err := json.Unmarshal(e.After, &after); err != nil {
+				t.Fatalf("failed to decode after: %v", err)
+			}
+			if after["id"] != tc.entityID {
+				t.Errorf("after id: got %v, want %s", after["id"], tc.entityID)
+			}
+
+			if _, err := audit.Verify(ctx, tc.store); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestTransitionIsAudited(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+
+	resp, shipment := h.createShipment(t, shipmentBody("ALMATY-ASTANA", randomIDN()))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST: got status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if _, err := h.shipments.TransitionShipment(ctx, shipment.ID, shipmentrepo.StatusInTransit); err != nil {
+		t.Fatalf("TransitionShipment: %v", err)
+	}
+
+	entries, err := h.shipmentAudit.ListAudit(ctx, audit.Filter{
+		EntityType: audit.EntityShipment,
+		EntityID:   shipment.ID,
+		Action:     audit.ActionUpdate,
+		Limit:      10,
+	})
+	if err != nil {
+		t.Fatalf("ListAudit: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d update entries, want 1", len(entries))
+	}
+
+	// Only the changed field is recorded.
+	e := entries[0]
+	for _, side := range []struct {
+		name string
+		raw  json.RawMessage
+		want string
+	}{
+		{"before", e.Before, shipmentrepo.StatusCreated},
+		{"after", e.After, shipmentrepo.StatusInTransit},
+	} {
+		var fields map[string]any
+		if err := json.Unmarshal(side.raw, &fields); err != nil {
+			t.Fatalf("failed to decode %s: %v", side.name, err)
+		}
+		if len(fields) != 1 || fields["status"] != side.want {
+			t.Errorf("%s: got %s, want only status %s", side.name, side.raw, side.want)
+		}
+	}
+
+	if _, err := audit.Verify(ctx, h.shipmentAudit); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+// tamperedStore returns the entries of a store with the after state of one
+// sequence rewritten, as if the stored entry had been changed.
+type tamperedStore struct {
+	audit.Store
+	sequence int64
+}
+
+func (s tamperedStore) ListAudit(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error) {
+	entries, err := s.Store.ListAudit(ctx, filter)
+	for _, e := range entries {
+		if e.Sequence == s.sequence {
+			e.After = json.RawMessage(`{"status":"DELIVERED"}`)
+		}
+	}
+	return entries, err
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	store := shipmentrepo.NewMemoryRepository()
+
+	shipment := &shipmentrepo.Shipment{Route: "ALMATY-ASTANA", Price: 100, CustomerID: "c1"}
+	if err := store.CreateShipment(ctx, shipment); err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	if err := store.UpdateShipmentStatus(ctx, shipment.ID, shipmentrepo.StatusCreated, shipmentrepo.StatusInTransit); err != nil {
+		t.Fatalf("UpdateShipmentStatus: %v", err)
+	}
+	if err := store.UpdateShipmentStatus(ctx, shipment.ID, shipmentrepo.StatusInTransit, shipmentrepo.StatusDelivered); err != nil {
+		t.Fatalf("UpdateShipmentStatus: %v", err)
+	}
+
+	checked, err := audit.Verify(ctx, store)
+	if err != nil || checked != 3 {
+		t.Fatalf("Verify: got %d, %v, want 3 entries and no error", checked, err)
+	}
+
+	checked, err = audit.Verify(ctx, tamperedStore{Store: store, sequence: 2})
+	if !errors.Is(err, audit.ErrTampered) {
+		t.Fatalf("Verify after changing entry 2: got %v, want ErrTampered", err)
+	}
+	if checked != 1 {
+		t.Errorf("got %d entries checked before the break, want 1", checked)
+	}
+}
@@ -0,0 +1,288 @@
+package integration
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "testovoe/api/proto"
+	"testovoe/internal/audit"
+	"testovoe/internal/auth"
+	"testovoe/internal/health"
+	"testovoe/internal/shipment/grpcserver"
+)
+
+const testIssuer = "https://issuer.test"
+
+// signer issues ES256 tokens with its kid.
+type signer struct {
+	kid string
+	key *ecdsa.PrivateKey
+}
+
+func newSigner(t *testing.T, kid string) *signer {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return &signer{kid: kid, key: key}
+}
+
+func (s *signer) jwk() map[string]string {
+	enc := base64.RawURLEncoding
+	return map[string]string{
+		"kty": "EC",
+		"crv": "P-256",
+		"kid": s.kid,
+		"alg": "ES256",
+		"use": "sig",
+		"x":   enc.EncodeToString(s.key.X.FillBytes(make([]byte, 32))),
+		"y":   enc.EncodeToString(s.key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func (s *signer) token(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	tok.Header["kid"] = s.kid
+	signed, err := tok.SignedString(s.key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func writeJWKS(t *testing.T, path string, signers ...*signer) {
+	t.Helper()
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for _, s := range signers {
+		set.Keys = append(set.Keys, s.jwk())
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("failed to encode JWKS: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write JWKS: %v", err)
+	}
+}
+
+func claims(sub, scope string, exp time.Duration) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"iss":   testIssuer,
+		"exp":   time.Now().Add(exp).Unix(),
+		"scope": scope,
+	}
+	if sub != "" {
+		c["sub"] = sub
+	}
+	return c
+}
+
+func (h *harness) postShipment(t *testing.T, token string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, h.api.URL+"/api/v1/shipments",
+		strings.NewReader(shipmentBody("ALMATY-ASTANA", randomIDN())))
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /shipments: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// newTestAuthenticator returns an authenticator trusting the keys of
+// signers, and the key set so that tests can reload it.
+func newTestAuthenticator(t *testing.T, path string, signers ...*signer) (*auth.Authenticator, *auth.KeySet) {
+	t.Helper()
+
+	writeJWKS(t, path, signers...)
+	keys, err := auth.NewKeySet(path)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return auth.NewAuthenticator(keys, auth.Config{
+		Issuer:     testIssuer,
+		ReadScope:  "shipments:read",
+		WriteScope: "shipments:write",
+	}), keys
+}
+
+func TestAuthentication(t *testing.T) {
+	h := newHarness(t)
+	current, other := newSigner(t, "current"), newSigner(t, "other")
+	// Unique so that runs sharing a Postgres database see only their entries.
+	alice := "alice-" + randomIDN()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	authenticator, keys := newTestAuthenticator(t, path, current)
+	h.routes.Use(authenticator.Middleware)
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"garbage", "not-a-jwt", http.StatusUnauthorized},
+		{"expired", current.token(t, claims(alice, "shipments:write", -time.Hour)), http.StatusUnauthorized},
+		{"no sub", current.token(t, claims("", "shipments:write", time.Hour)), http.StatusUnauthorized},
+		{"unknown key", other.token(t, claims(alice, "shipments:write", time.Hour)), http.StatusUnauthorized},
+		{"read scope only", current.token(t, claims(alice, "shipments:read", time.Hour)), http.StatusForbidden},
+		{"valid", current.token(t, claims(alice, "shipments:read shipments:write", time.Hour)), http.StatusCreated},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := h.postShipment(t, tc.token)
+			if resp.StatusCode != tc.want {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tc.want)
+			}
+			if tc.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+
+	t.Run("actor is audited", func(t *testing.T) {
+		entries, err := h.shipmentAudit.ListAudit(context.Background(), audit.Filter{Actor: alice, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListAudit: %v", err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("got %d entries by %s, want 1", len(entries), alice)
+		}
+	})
+
+	t.Run("key rotation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go keys.Run(ctx, 10*time.Millisecond)
+
+		writeJWKS(t, path, current, other)
+		token := other.token(t, claims("bob", "shipments:write", time.Hour))
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			resp := h.postShipment(t, token)
+			if resp.StatusCode == http.StatusCreated {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("new key not picked up: last status %d", resp.StatusCode)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	h := newHarness(t)
+	current := newSigner(t, "current")
+	authenticator, _ := newTestAuthenticator(t, filepath.Join(t.TempDir(), "jwks.json"), current)
+
+	srv, err := grpcserver.StartGRPCServer(context.Background(), grpcserver.Options{
+		Port:          "0",
+		Authenticator: authenticator,
+	}, h.shipments, h.shipmentStore.(health.Pinger))
+	if err != nil {
+		t.Fatalf("StartGRPCServer: %v", err)
+	}
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient(srv.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := pb.NewShipmentServiceClient(conn)
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+	req := &pb.CreateShipmentRequest{Route: "ALMATY-ASTANA", Price: 100, CustomerIdn: randomIDN()}
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"no token", context.Background(), codes.Unauthenticated},
+		{"garbage", withToken("not-a-jwt"), codes.Unauthenticated},
+		{"read scope only", withToken(current.token(t, claims("carol", "shipments:read", time.Hour))), codes.PermissionDenied},
+		{"valid", withToken(current.token(t, claims("carol", "shipments:write", time.Hour))), codes.OK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.CreateShipment(tc.ctx, req)
+			if got := status.Code(err); got != tc.want {
+				t.Fatalf("got %v, want %v (%v)", got, tc.want, err)
+			}
+		})
+	}
+
+	t.Run("health is open", func(t *testing.T) {
+		if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+	})
+}
+
+func TestJWKSSkipsUnusableKeys(t *testing.T) {
+	current := newSigner(t, "current")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+
+	data, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "OKP", "crv": "Ed25519", "kid": "ed", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+		{"kty": "EC", "crv": "P-384", "kid": "p384", "x": "AA", "y": "AA"},
+		current.jwk(),
+	}})
+	if err != nil {
+		t.Fatalf("failed to encode JWKS: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write JWKS: %v", err)
+	}
+	if _, err := auth.NewKeySet(path); err != nil {
+		t.Fatalf("NewKeySet with one usable key: %v", err)
+	}
+
+	data, _ = json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "OKP", "crv": "Ed25519", "kid": "ed", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+	}})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write JWKS: %v", err)
+	}
+	if _, err := auth.NewKeySet(path); err == nil {
+		t.Fatal("NewKeySet without usable keys: got nil error")
+	}
+}
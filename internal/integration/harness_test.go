@@ -0,0 +1,140 @@
+// Package integration runs customer-service and shipment-service in one
+// process: the customer gRPC server listens on an in-memory bufconn, the
+// shipment gRPC client dials it, and the shipment HTTP handler is served by
+// httptest.
+//
+// The stores are in memory by default. Set TEST_POSTGRES_DSN to run the
+// same tests against a local Postgres; the migrations of both services are
+// applied to that database.
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"net"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "testovoe/api/proto"
+	"testovoe/internal/audit"
+	"testovoe/internal/config"
+	customergrpc "testovoe/internal/customer/grpc"
+	customerrepo "testovoe/internal/customer/repo"
+	customerservice "testovoe/internal/customer/service"
+	"testovoe/internal/migrate"
+	shipmentgrpc "testovoe/internal/shipment/grpc"
+	httphandler "testovoe/internal/shipment/http"
+	shipmentrepo "testovoe/internal/shipment/repo"
+	shipmentservice "testovoe/internal/shipment/service"
+	"testovoe/migrations"
+)
+
+type harness struct {
+	// customers is the shipment-service client wired to the in-process
+	// customer-service.
+	customers *shipmentgrpc.Client
+	// api serves the shipment REST API under /api/v1.
+	api *httptest.Server
+	// routes is the /api/v1 subrouter; tests may add middleware to it.
+	routes *mux.Router
+	// shipments and shipmentStore back the API, for tests that serve it
+	// another way.
+	shipments     *shipmentservice.Service
+	shipmentStore shipmentservice.Repository
+	// customerAudit and shipmentAudit are the audit logs of the stores.
+	customerAudit, shipmentAudit audit.Store
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	customerStore, shipmentStore := newStores(t)
+
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	pb.RegisterCustomerServiceServer(grpcServer,
+		customergrpc.NewServer(customerservice.NewService(customerStore), nil))
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	client, err := shipmentgrpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create customer client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	svc := shipmentservice.NewService(shipmentStore, client)
+	router := mux.NewRouter()
+	routes := router.PathPrefix("/api/v1").Subrouter()
+	httphandler.NewHandler(svc).RegisterRoutes(routes)
+
+	api := httptest.NewServer(router)
+	t.Cleanup(api.Close)
+
+	return &harness{
+		customers:     client,
+		api:           api,
+		routes:        routes,
+		shipments:     svc,
+		shipmentStore: shipmentStore,
+		customerAudit: customerStore.(audit.Store),
+		shipmentAudit: shipmentStore.(audit.Store),
+	}
+}
+
+func newStores(t *testing.T) (customerservice.Repository, shipmentservice.Repository) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		return customerrepo.NewMemoryRepository(), shipmentrepo.NewMemoryRepository()
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to %s: %v", dsn, err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrateUp(t, pool, config.CustomerService, migrations.Customer)
+	migrateUp(t, pool, config.ShipmentService, migrations.Shipment)
+
+	return customerrepo.NewRepository(pool), shipmentrepo.NewRepository(pool)
+}
+
+func migrateUp(t *testing.T, pool *pgxpool.Pool, service string, files func(dialect string) (fs.FS, error)) {
+	t.Helper()
+
+	fsys, err := files(config.StoragePostgres)
+	if err != nil {
+		t.Fatalf("failed to load %s migrations: %v", service, err)
+	}
+	runner, err := migrate.New(pool, service, fsys)
+	if err != nil {
+		t.Fatalf("failed to load %s migrations: %v", service, err)
+	}
+	defer runner.Close()
+
+	if _, err := runner.Up(context.Background()); err != nil {
+		t.Fatalf("failed to apply %s migrations: %v", service, err)
+	}
+}
+
+// randomIDN returns a valid IDN that no other test uses, so tests sharing a
+// Postgres database do not see each other's customers.
+func randomIDN() string {
+	return fmt.Sprintf("%012d", rand.Int64N(1e12))
+}
@@ -0,0 +1,49 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"testovoe/internal/problem"
+	"testovoe/internal/ratelimit"
+	"testovoe/internal/reqctx"
+)
+
+func TestRateLimitProblem(t *testing.T) {
+	h := newHarness(t)
+	limiter := ratelimit.New()
+	limiter.Update(0.001, 1)
+	h.routes.Use(reqctx.Middleware, ratelimit.Middleware(limiter))
+
+	resp := h.postShipment(t, "")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("first request: got status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	resp, err := http.Post(h.api.URL+"/api/v1/shipments", "application/json",
+		strings.NewReader(shipmentBody("ALMATY-ASTANA", randomIDN())))
+	if err != nil {
+		t.Fatalf("POST /shipments: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second request: got status %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("got Content-Type %q, want application/problem+json", ct)
+	}
+
+	var p problem.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	if p.Status != http.StatusTooManyRequests {
+		t.Errorf("got status member %d, want %d", p.Status, http.StatusTooManyRequests)
+	}
+	if p.RequestID == "" || p.RequestID != resp.Header.Get(reqctx.HeaderRequestID) {
+		t.Errorf("got request_id %q, want the %s header %q", p.RequestID, reqctx.HeaderRequestID, resp.Header.Get(reqctx.HeaderRequestID))
+	}
+}
@@ -0,0 +1,41 @@
+package integration
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"testovoe/internal/redact"
+)
+
+const testIDN = "990101300256"
+
+// customerRef logs itself as a group holding the IDN.
+type customerRef struct{ idn string }
+
+func (c customerRef) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("idn", c.idn))
+}
+
+type idnHolder struct{ IDN string }
+
+func TestLogAttrRedactsResolvedValues(t *testing.T) {
+	for _, a := range []slog.Attr{
+		slog.Any("customer", customerRef{idn: testIDN}),
+		slog.Group("request", slog.Any("customer", customerRef{idn: testIDN})),
+		slog.Any("holder", idnHolder{IDN: testIDN}),
+		slog.Any("ids", []string{testIDN}),
+	} {
+		got := redact.LogAttr(a)
+		if s := got.String(); strings.Contains(s, testIDN) {
+			t.Errorf("%s: IDN left in %s", a.Key, s)
+		}
+	}
+}
+
+func TestLogAttrKeepsValuesWithoutIDN(t *testing.T) {
+	a := slog.Any("ids", []int{1, 2})
+	if got := redact.LogAttr(a); got.Value.Kind() != slog.KindAny {
+		t.Errorf("got kind %s, want %s", got.Value.Kind(), slog.KindAny)
+	}
+}
@@ -0,0 +1,47 @@
+package integration
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"testovoe/internal/config"
+)
+
+func TestReloadReportsRestartSettingOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(interval string) {
+		t.Helper()
+		yaml := "storage: memory\ngrpc:\n  health_interval: " + interval + "\n"
+		if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+	}
+
+	write("5s")
+	args := []string{"-config", path}
+	cfg, err := config.Load("shipment-service", args)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	r := config.NewReloader(cfg, args)
+
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	write("10s")
+	for range 3 {
+		if err := r.Reload(); err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+	}
+
+	if n := strings.Count(logs.String(), "apply after a restart"); n != 1 {
+		t.Errorf("got %d restart warnings over 3 reloads, want 1:\n%s", n, logs.String())
+	}
+}
@@ -0,0 +1,82 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/baggage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"testovoe/internal/reqctx"
+)
+
+func TestLongTenantIsCutOnRuneBoundary(t *testing.T) {
+	// The leading ASCII byte puts the 128-byte limit inside a Cyrillic rune.
+	tenant := "x" + strings.Repeat("Тенант", 30)
+
+	var got string
+	handler := reqctx.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = baggage.FromContext(r.Context()).Member(reqctx.KeyTenant).Value()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(reqctx.HeaderTenant, tenant)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got == "" {
+		t.Fatal("tenant missing from baggage")
+	}
+	if !utf8.ValidString(got) {
+		t.Fatalf("tenant %q is not valid UTF-8", got)
+	}
+	if len(got) > 128 || !strings.HasPrefix(tenant, got) {
+		t.Errorf("got tenant %q (%d bytes), want a prefix of at most 128 bytes", got, len(got))
+	}
+}
+
+func TestGRPCEdgeDiscardsClientBaggage(t *testing.T) {
+	var members []baggage.Member
+	for _, kv := range [][2]string{
+		{reqctx.KeyTenant, "forged"},
+		{reqctx.KeyChannel, "forged"},
+		{reqctx.KeyRequestID, "forged"},
+	} {
+		m, err := baggage.NewMemberRaw(kv[0], kv[1])
+		if err != nil {
+			t.Fatalf("NewMemberRaw: %v", err)
+		}
+		members = append(members, m)
+	}
+	b, err := baggage.New(members...)
+	if err != nil {
+		t.Fatalf("baggage.New: %v", err)
+	}
+
+	ctx := baggage.ContextWithBaggage(context.Background(), b)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(reqctx.MetadataTenant, "acme"))
+
+	var got baggage.Baggage
+	_, err = reqctx.EdgeUnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, _ any) (any, error) {
+			got = baggage.FromContext(ctx)
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+
+	if v := got.Member(reqctx.KeyTenant).Value(); v != "acme" {
+		t.Errorf("tenant: got %q, want acme from metadata", v)
+	}
+	if v := got.Member(reqctx.KeyChannel).Value(); v != "" {
+		t.Errorf("channel: got %q, want none", v)
+	}
+	if v := got.Member(reqctx.KeyRequestID).Value(); v == "" || v == "forged" {
+		t.Errorf("request_id: got %q, want a generated one", v)
+	}
+}
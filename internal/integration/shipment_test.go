@@ -0,0 +1,187 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	httphandler "testovoe/internal/shipment/http"
+)
+
+func (h *harness) createShipment(t *testing.T, body string) (*http.Response, httphandler.ShipmentResponse) {
+	t.Helper()
+
+	resp, err := http.Post(h.api.URL+"/api/v1/shipments", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /shipments: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var shipment httphandler.ShipmentResponse
+	if resp.StatusCode == http.StatusCreated {
+		if err := json.NewDecoder(resp.Body).Decode(&shipment); err != nil {
+			t.Fatalf("failed to decode shipment: %v", err)
+		}
+	}
+	return resp, shipment
+}
+
+func shipmentBody(route, idn string) string {
+	return `{"route":"` + route + `","price":100.5,"customer":{"idn":"` + idn + `"}}`
+}
+
+func TestCreateShipmentReusesCustomer(t *testing.T) {
+	h := newHarness(t)
+	idn := randomIDN()
+
+	resp, first := h.createShipment(t, shipmentBody("ALMATY-ASTANA", idn))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("first POST: got status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	resp, second := h.createShipment(t, shipmentBody("ASTANA-SHYMKENT", idn))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("second POST: got status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	if first.ID == second.ID {
+		t.Errorf("both shipments got id %s", first.ID)
+	}
+	if first.CustomerID == "" || first.CustomerID != second.CustomerID {
+		t.Errorf("customer ids differ: %q and %q", first.CustomerID, second.CustomerID)
+	}
+
+	customer, err := h.customers.GetCustomer(context.Background(), idn)
+	if err != nil {
+		t.Fatalf("GetCustomer: %v", err)
+	}
+	if customer.Id != first.CustomerID {
+		t.Errorf("customer-service has id %s for the IDN, shipments use %s", customer.Id, first.CustomerID)
+	}
+}
+
+func TestUpsertCustomerIsIdempotent(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	idn := randomIDN()
+
+	first, err := h.customers.UpsertCustomer(ctx, idn)
+	if err != nil {
+		t.Fatalf("first UpsertCustomer: %v", err)
+	}
+	second, err := h.customers.UpsertCustomer(ctx, idn)
+	if err != nil {
+		t.Fatalf("second UpsertCustomer: %v", err)
+	}
+
+	if first.Id != second.Id || first.CreatedAt != second.CreatedAt {
+		t.Errorf("upsert created a second customer: %v and %v", first, second)
+	}
+	if first.Idn != idn {
+		t.Errorf("got idn %q, want %q", first.Idn, idn)
+	}
+}
+
+func TestGetShipment(t *testing.T) {
+	h := newHarness(t)
+
+	resp, created := h.createShipment(t, shipmentBody("ALMATY-ASTANA", randomIDN()))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST: got status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{"existing", created.ID, http.StatusOK},
+		{"unknown", uuid.New().String(), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(h.api.URL + "/api/v1/shipments/" + tt.id)
+			if err != nil {
+				t.Fatalf("GET: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got httphandler.ShipmentResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode shipment: %v", err)
+			}
+			if got.ID != created.ID || got.CustomerID != created.CustomerID || got.Route != created.Route {
+				t.Errorf("got %+v, want %+v", got, created)
+			}
+		})
+	}
+}
+
+func TestCreateShipmentValidation(t *testing.T) {
+	h := newHarness(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed json", `{"route":`},
+		{"short idn", shipmentBody("ALMATY-ASTANA", "12345")},
+		{"empty idn", shipmentBody("ALMATY-ASTANA", "")},
+		// Passes the length check here and is rejected by customer-service.
+		{"non-digit idn", shipmentBody("ALMATY-ASTANA", "99010112345X")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := h.createShipment(t, tt.body)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestCustomerErrorCodes(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"get unknown customer", func() error {
+			_, err := h.customers.GetCustomer(ctx, randomIDN())
+			return err
+		}, codes.NotFound},
+		{"get with invalid idn", func() error {
+			_, err := h.customers.GetCustomer(ctx, "abc")
+			return err
+		}, codes.InvalidArgument},
+		{"upsert with invalid idn", func() error {
+			_, err := h.customers.UpsertCustomer(ctx, "1234567890123")
+			return err
+		}, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Errorf("got code %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
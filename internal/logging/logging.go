@@ -0,0 +1,115 @@
+// Package logging configures the process-wide logger.
+//
+// Records are written as JSON (or text) to stderr. Records logged with a
+// context carrying a span get trace_id and span_id attributes, so log sites
+// only need to use the *Context variants of the slog functions; the same
+// goes for request-scoped attributes attached with WithAttrs. IDNs in
+// messages and attributes are redacted according to the redact policy.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Output formats accepted by Configure.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+var level = new(slog.LevelVar)
+
+// Setup installs the default JSON logger on stderr. Output from the
+// standard log package goes through it as well, at INFO level. Main calls
+// it first thing so configuration errors are logged in the same format;
+// Configure applies the configured format afterwards.
+func Setup() {
+	slog.SetDefault(slog.New(contextHandler{redactHandler{newHandler(os.Stderr, FormatJSON)}}))
+}
+
+// Configure replaces the default logger with one writing format to stderr.
+// Each record is also passed to the extra handlers, such as the OTel logs
+// bridge; they are filtered by the same level.
+func Configure(format string, extra ...slog.Handler) error {
+	if format != FormatJSON && format != FormatText {
+		return fmt.Errorf("invalid log format %q", format)
+	}
+
+	var h slog.Handler = newHandler(os.Stderr, format)
+	if len(extra) > 0 {
+		h = fanout(append([]slog.Handler{h}, extra...))
+	}
+	slog.SetDefault(slog.New(contextHandler{redactHandler{h}}))
+	return nil
+}
+
+// SetLevel changes the minimum level of the default logger at runtime.
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Fatal logs msg at ERROR level and exits with status 1.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func newHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == FormatText {
+		return traceHandler{slog.NewTextHandler(w, opts)}
+	}
+	return traceHandler{slog.NewJSONHandler(w, opts)}
+}
+
+// fanout passes each record to every handler that accepts its level.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, l slog.Level) bool {
+	if l < level.Level() {
+		return false
+	}
+	for _, h := range f {
+		if h.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"testovoe/internal/redact"
+)
+
+// redactHandler removes IDNs from the message and attributes before any
+// output, including the OTel logs bridge, sees the record.
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !redact.Enabled() {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	out := slog.NewRecord(r.Time, r.Level, redact.String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redact.LogAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = redact.LogAttr(a)
+	}
+	return redactHandler{h.Handler.WithAttrs(out)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,151 @@
+// Package metrics records RED (rate, errors, duration) metrics for the HTTP
+// routes and RPC methods of both services.
+//
+// Each request is recorded once in a duration histogram; its count gives
+// the rate and its status attribute the errors. Instruments are created on
+// the global MeterProvider, so they start exporting once main installs it.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var meter = otel.Meter("testovoe/internal/metrics")
+
+// durationBuckets are the boundaries semantic conventions recommend for
+// request durations in seconds.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+var (
+	httpDuration, _ = meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of HTTP server requests"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	rpcDuration, _ = meter.Float64Histogram("rpc.server.call.duration",
+		metric.WithDescription("Duration of RPCs handled by the server"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+)
+
+// RecordHTTP records one HTTP request. route is the route template (for
+// example /api/v1/shipments/{id}), never the raw path, to keep the number
+// of series bounded.
+func RecordHTTP(ctx context.Context, method, route string, statusCode int, elapsed time.Duration) {
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", method),
+		attribute.String("http.route", route),
+		attribute.Int("http.response.status_code", statusCode),
+	}
+	if statusCode >= 500 {
+		attrs = append(attrs, attribute.String("error.type", http.StatusText(statusCode)))
+	}
+	httpDuration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// RecordRPC records one RPC. fullMethod has the gRPC form
+// /package.Service/Method; system is "grpc" or "connect_rpc".
+func RecordRPC(ctx context.Context, system, fullMethod string, code codes.Code, elapsed time.Duration) {
+	service, method := splitMethod(fullMethod)
+	rpcDuration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(
+		attribute.String("rpc.system", system),
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", method),
+		attribute.Int("rpc.grpc.status_code", int(code)),
+	))
+}
+
+func splitMethod(fullMethod string) (string, string) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "unknown", name
+}
+
+// MuxMiddleware records requests handled by a gorilla/mux router, labelled
+// with the matched route template.
+func MuxMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		RecordHTTP(r.Context(), r.Method, route, sw.status, time.Since(start))
+	})
+}
+
+// UnaryServerInterceptor records every unary gRPC call.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		RecordRPC(ctx, "grpc", info.FullMethod, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
+
+// ConnectInterceptor records every unary Connect and gRPC-Web call.
+func ConnectInterceptor() connect.Interceptor {
+	return connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			start := time.Now()
+			resp, err := next(ctx, req)
+			code := codes.OK
+			if err != nil {
+				code = codes.Code(connect.CodeOf(err))
+			}
+			RecordRPC(ctx, "connect_rpc", req.Spec().Procedure, code, time.Since(start))
+			return resp, err
+		}
+	})
+}
+
+// StatusWriter wraps w to remember the status code written to it.
+func StatusWriter(w http.ResponseWriter) (http.ResponseWriter, func() int) {
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	return sw, func() int { return sw.status }
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
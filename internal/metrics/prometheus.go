@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"testovoe/internal/reqctx"
+)
+
+// NewPrometheusReader returns a metric reader for the MeterProvider and the
+// handler serving what it collects in the Prometheus exposition format.
+// It uses its own registry, so only this process's OTel metrics plus the
+// Go runtime and process collectors are exposed.
+func NewPrometheusReader() (sdkmetric.Reader, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	exporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create Prometheus exporter: %w", err)
+	}
+
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+	return exporter, handler, nil
+}
+
+// NewAdminServer returns the HTTP server for the admin port. It serves
+// /metrics when metrics is not nil, and routes, keyed by ServeMux pattern.
+// The port is not published by Envoy, so it carries the internal endpoints
+// and scrapes bypass rate limiting and the RED middleware. The routes get a
+// request ID for their logs and error bodies.
+func NewAdminServer(port string, metrics http.Handler, routes map[string]http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	if metrics != nil {
+		mux.Handle("GET /metrics", metrics)
+	}
+	for pattern, h := range routes {
+		mux.Handle(pattern, reqctx.Middleware(h))
+	}
+
+	return &http.Server{
+		Addr:    ":" + port,
+		Handler: mux,
+	}
+}
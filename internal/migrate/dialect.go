@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+)
+
+// dialect holds the engine-specific SQL and locking of the runner.
+type dialect struct {
+	createTable   string
+	selectApplied string
+	insert        string
+	insertIgnore  string
+	delete        string
+	deleteAbove   string
+
+	// lock takes the migration lock for service on conn and returns the
+	// function releasing it.
+	lock func(ctx context.Context, conn *sql.Conn, service string) (func(), error)
+	// singleTx runs a whole command in one transaction instead of one
+	// transaction per migration.
+	singleTx bool
+}
+
+var postgres = &dialect{
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		service TEXT NOT NULL,
+		version BIGINT NOT NULL,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (service, version)
+	)`,
+	selectApplied: `SELECT version, applied_at FROM schema_migrations WHERE service = $1`,
+	insert:        `INSERT INTO schema_migrations (service, version, name) VALUES ($1, $2, $3)`,
+	insertIgnore: `INSERT INTO schema_migrations (service, version, name) VALUES ($1, $2, $3)
+		ON CONFLICT (service, version) DO NOTHING`,
+	delete:      `DELETE FROM schema_migrations WHERE service = $1 AND version = $2`,
+	deleteAbove: `DELETE FROM schema_migrations WHERE service = $1 AND version > $2`,
+
+	// The advisory lock is session-scoped, so it is released on unlock even
+	// if a migration failed halfway.
+	lock: func(ctx context.Context, conn *sql.Conn, service string) (func(), error) {
+		key := lockKey(service)
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+			return nil, err
+		}
+		return func() {
+			conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key)
+		}, nil
+	},
+}
+
+var sqlite = &dialect{
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		service TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (service, version)
+	)`,
+	selectApplied: `SELECT version, applied_at FROM schema_migrations WHERE service = ?1`,
+	insert:        `INSERT INTO schema_migrations (service, version, name) VALUES (?1, ?2, ?3)`,
+	insertIgnore: `INSERT INTO schema_migrations (service, version, name) VALUES (?1, ?2, ?3)
+		ON CONFLICT (service, version) DO NOTHING`,
+	delete:      `DELETE FROM schema_migrations WHERE service = ?1 AND version = ?2`,
+	deleteAbove: `DELETE FROM schema_migrations WHERE service = ?1 AND version > ?2`,
+
+	// The write transaction opened by withLock is the lock: SQLite allows
+	// one writer per database file.
+	lock: func(ctx context.Context, conn *sql.Conn, service string) (func(), error) {
+		return func() {}, nil
+	},
+	singleTx: true,
+}
+
+func lockKey(service string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("schema_migrations:" + service))
+	return int64(h.Sum64())
+}
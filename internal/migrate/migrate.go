@@ -0,0 +1,354 @@
+// Package migrate applies the embedded SQL migrations of a service and
+// records them in the schema_migrations table.
+//
+// On Postgres each migration runs in its own transaction together with its
+// bookkeeping row, and the whole run holds an advisory lock so concurrent
+// runners (for example several replicas starting at once) wait for each
+// other instead of applying the same migration twice. SQLite has a single
+// writer, so there the whole run is one write transaction.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+var fileRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+var ErrNoMigration = errors.New("no such migration")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Runner struct {
+	db         *sql.DB
+	ownsDB     bool
+	dialect    *dialect
+	service    string
+	migrations []Migration
+}
+
+// New reads the Postgres migrations in fsys. service keys the rows in
+// schema_migrations and the advisory lock, so services sharing a database
+// keep separate histories. Close the runner when done; the pool stays open.
+func New(pool *pgxpool.Pool, service string, fsys fs.FS) (*Runner, error) {
+	r, err := newRunner(stdlib.OpenDBFromPool(pool), postgres, service, fsys)
+	if err != nil {
+		return nil, err
+	}
+	r.ownsDB = true
+	return r, nil
+}
+
+// NewSQLite reads the SQLite migrations in fsys. db should be opened with
+// database.OpenSQLite so transactions take the write lock up front.
+func NewSQLite(db *sql.DB, service string, fsys fs.FS) (*Runner, error) {
+	return newRunner(db, sqlite, service, fsys)
+}
+
+// Close releases the database handle New opened over the pool. A db passed
+// to NewSQLite belongs to the caller and is left open.
+func (r *Runner) Close() error {
+	if !r.ownsDB {
+		return nil
+	}
+	return r.db.Close()
+}
+
+func newRunner(db *sql.DB, d *dialect, service string, fsys fs.FS) (*Runner, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{db: db, dialect: d, service: service, migrations: migrations}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := fileRegex.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(".", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in order and returns how many ran.
+func (r *Runner) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := r.withLock(ctx, func(q querier) error {
+		done, err := r.appliedVersions(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range r.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := r.apply(ctx, q, mig, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return applied, nil
+}
+
+// Down rolls back the last steps applied migrations, newest first.
+func (r *Runner) Down(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+	err := r.withLock(ctx, func(q querier) error {
+		done, err := r.appliedVersions(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		for i := len(r.migrations) - 1; i >= 0 && rolledBack < steps; i-- {
+			mig := r.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+			}
+			if err := r.apply(ctx, q, mig, false); err != nil {
+				return err
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rolledBack, nil
+}
+
+// Status lists every known migration and whether it has been applied.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := r.withLock(ctx, func(q querier) error {
+		done, err := r.appliedVersions(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range r.migrations {
+			appliedAt, ok := done[mig.Version]
+			statuses = append(statuses, Status{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Force records version and every earlier migration as applied, and every
+// later one as not applied, without running any SQL. It is meant for
+// repairing the history after a schema change was made by hand.
+func (r *Runner) Force(ctx context.Context, version int64) error {
+	if version != 0 && !r.known(version) {
+		return fmt.Errorf("%w: %d", ErrNoMigration, version)
+	}
+
+	return r.withLock(ctx, func(q querier) error {
+		return inTx(ctx, q, func(tx querier) error {
+			if _, err := tx.ExecContext(ctx, r.dialect.deleteAbove, r.service, version); err != nil {
+				return fmt.Errorf("failed to force version: %w", err)
+			}
+			for _, mig := range r.migrations {
+				if mig.Version > version {
+					break
+				}
+				if _, err := tx.ExecContext(ctx, r.dialect.insertIgnore, r.service, mig.Version, mig.Name); err != nil {
+					return fmt.Errorf("failed to force version: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func (r *Runner) known(version int64) bool {
+	for _, mig := range r.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Runner) apply(ctx context.Context, q querier, mig Migration, up bool) error {
+	direction, script := "up", mig.Up
+	if !up {
+		direction, script = "down", mig.Down
+	}
+
+	err := inTx(ctx, q, func(tx querier) error {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return err
+		}
+		if up {
+			_, err := tx.ExecContext(ctx, r.dialect.insert, r.service, mig.Version, mig.Name)
+			return err
+		}
+		_, err := tx.ExecContext(ctx, r.dialect.delete, r.service, mig.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %w", mig.Version, mig.Name, direction, err)
+	}
+	return nil
+}
+
+func (r *Runner) appliedVersions(ctx context.Context, q querier) (map[int64]time.Time, error) {
+	rows, err := q.QueryContext(ctx, r.dialect.selectApplied, r.service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+// withLock runs fn on a single connection holding the dialect's migration
+// lock. On SQLite fn runs inside one transaction that is committed only if
+// fn succeeds.
+func (r *Runner) withLock(ctx context.Context, fn func(q querier) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	unlock, err := r.dialect.lock(ctx, conn, r.service)
+	if err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer unlock()
+
+	if _, err := conn.ExecContext(ctx, r.dialect.createTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	if !r.dialect.singleTx {
+		return fn(conn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// querier is implemented by *sql.Conn and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// inTx runs fn in a new transaction when q is a connection, or directly
+// when q already is a transaction.
+func inTx(ctx context.Context, q querier, fn func(tx querier) error) error {
+	conn, ok := q.(*sql.Conn)
+	if !ok {
+		return fn(q)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
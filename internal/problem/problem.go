@@ -0,0 +1,51 @@
+// Package problem writes RFC 9457 application/problem+json error responses.
+package problem
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"testovoe/internal/reqctx"
+)
+
+// Problem is an RFC 9457 error body. RequestID and TraceID let a client
+// quote the failed request in a support ticket.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+}
+
+// Write writes an application/problem+json response. Details of
+// 5xx errors stay in the log; the client only gets the request ID.
+func Write(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	ctx := r.Context()
+
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "Request failed", "status", status, "error", detail)
+		detail = ""
+	}
+
+	p := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: reqctx.RequestID(ctx),
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		p.TraceID = sc.TraceID().String()
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
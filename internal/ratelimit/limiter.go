@@ -0,0 +1,74 @@
+// Package ratelimit provides a process-wide request limiter whose rate can
+// be changed while requests are in flight.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"testovoe/internal/problem"
+)
+
+// Limiter is a token bucket that allows everything until Update sets a
+// positive rate. A nil *Limiter allows everything too.
+type Limiter struct {
+	limiter atomic.Pointer[rate.Limiter]
+}
+
+func New() *Limiter {
+	return &Limiter{}
+}
+
+// Update replaces the bucket. rps <= 0 disables limiting; a burst below 1
+// defaults to the rate rounded up.
+func (l *Limiter) Update(rps float64, burst int) {
+	if rps <= 0 {
+		l.limiter.Store(nil)
+		return
+	}
+	if burst < 1 {
+		burst = int(math.Ceil(rps))
+	}
+	l.limiter.Store(rate.NewLimiter(rate.Limit(rps), burst))
+}
+
+func (l *Limiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+	lim := l.limiter.Load()
+	return lim == nil || lim.Allow()
+}
+
+// Middleware rejects requests over the limit with 429 Too Many Requests. It
+// must run inside reqctx.Middleware so that the error carries the request
+// ID.
+func Middleware(l *Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !l.Allow() {
+				problem.Write(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UnaryServerInterceptor rejects calls over the limit with
+// RESOURCE_EXHAUSTED.
+func UnaryServerInterceptor(l *Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !l.Allow() {
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return handler(ctx, req)
+	}
+}
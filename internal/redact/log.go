@@ -0,0 +1,42 @@
+package redact
+
+import (
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// LogAttr redacts a log attribute the way Attribute redacts span
+// attributes. LogValuers are resolved first, so a value that logs itself as
+// a group is descended into like any other group.
+func LogAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		v := a.Value.String()
+		if sensitiveKeys[attribute.Key(a.Key)] && digitRun.FindString(v) == v {
+			return slog.String(a.Key, IDN(v))
+		}
+		return slog.String(a.Key, String(v))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		out := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			out[i] = LogAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}
+	case slog.KindAny:
+		v := a.Value.Any()
+		if err, ok := v.(error); ok {
+			return slog.String(a.Key, String(err.Error()))
+		}
+		// Other values keep their type unless their text holds an IDN.
+		text := fmt.Sprint(v)
+		if redacted := String(text); redacted != text {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
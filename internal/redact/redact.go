@@ -0,0 +1,128 @@
+// Package redact keeps customer IDNs (ИИН) out of telemetry.
+//
+// Code that puts an IDN into a span attribute or a log record passes it
+// through IDN first. As a safety net, String replaces anything that looks
+// like an IDN inside free text such as URLs and span names; the span
+// processor from NewSpanProcessor and the logging handler apply it to
+// everything they see.
+//
+// The policy is process-wide and set once at startup with Configure.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Modes accepted by Configure.
+const (
+	// ModeMask keeps the first four and last two digits: 9901******56.
+	ModeMask = "mask"
+	// ModeHash replaces the IDN with a keyed hash, so the same customer can
+	// still be followed across traces without revealing the IDN.
+	ModeHash = "hash"
+	// ModeNone writes IDNs as is; meant for local development only.
+	ModeNone = "none"
+)
+
+// digitRun matches whole runs of digits. A run of exactly idnLength is
+// treated as an IDN unless it touches a letter or a hyphen, which makes it
+// part of a UUID or a hex identifier such as a trace ID.
+var digitRun = regexp.MustCompile(`\d+`)
+
+const idnLength = 12
+
+type policy struct {
+	mode string
+	key  []byte
+}
+
+var current atomic.Pointer[policy]
+
+func init() {
+	current.Store(&policy{mode: ModeMask})
+}
+
+// Configure sets the policy. ModeHash requires key: an unkeyed hash of a
+// 12-digit IDN is reversed by trying every IDN.
+func Configure(mode string, key string) error {
+	switch mode {
+	case ModeMask, ModeNone:
+	case ModeHash:
+		if key == "" {
+			return fmt.Errorf("redaction mode %s requires a key", ModeHash)
+		}
+	default:
+		return fmt.Errorf("invalid redaction mode %q", mode)
+	}
+	current.Store(&policy{mode: mode, key: []byte(key)})
+	return nil
+}
+
+// Enabled reports whether IDNs are redacted at all.
+func Enabled() bool {
+	return current.Load().mode != ModeNone
+}
+
+// IDN returns idn in the form allowed in telemetry.
+func IDN(idn string) string {
+	p := current.Load()
+	switch p.mode {
+	case ModeNone:
+		return idn
+	case ModeHash:
+		return hash(p.key, idn)
+	default:
+		return mask(idn)
+	}
+}
+
+// String redacts every IDN-like digit run in s.
+func String(s string) string {
+	if !Enabled() || !strings.ContainsAny(s, "0123456789") {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range digitRun.FindAllStringIndex(s, -1) {
+		start, end := loc[0], loc[1]
+		if end-start != idnLength || partOfID(s, start, end) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(IDN(s[start:end]))
+		last = end
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func partOfID(s string, start, end int) bool {
+	return (start > 0 && isIDChar(s[start-1])) || (end < len(s) && isIDChar(s[end]))
+}
+
+func isIDChar(c byte) bool {
+	return c == '-' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func mask(idn string) string {
+	if len(idn) <= 6 {
+		return strings.Repeat("*", len(idn))
+	}
+	return idn[:4] + strings.Repeat("*", len(idn)-6) + idn[len(idn)-2:]
+}
+
+func hash(key []byte, idn string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(idn))
+	return "sha256:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
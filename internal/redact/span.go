@@ -0,0 +1,103 @@
+package redact
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// sensitiveKeys are attributes that always hold an IDN, whatever it looks
+// like.
+var sensitiveKeys = map[attribute.Key]bool{
+	"customer.idn": true,
+	"idn":          true,
+}
+
+// NewSpanProcessor wraps next so that finished spans reach it with IDNs
+// redacted from the name, attributes and event attributes. It is the last
+// line of defence for values that did not go through IDN, such as URLs
+// recorded by the HTTP instrumentation.
+func NewSpanProcessor(next sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return &spanProcessor{next: next}
+}
+
+type spanProcessor struct {
+	next sdktrace.SpanProcessor
+}
+
+func (p *spanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *spanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !Enabled() {
+		p.next.OnEnd(s)
+		return
+	}
+	p.next.OnEnd(scrubbedSpan{ReadOnlySpan: s})
+}
+
+func (p *spanProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *spanProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// scrubbedSpan overrides the parts of a span that may carry an IDN.
+type scrubbedSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s scrubbedSpan) Name() string {
+	return String(s.ReadOnlySpan.Name())
+}
+
+func (s scrubbedSpan) Attributes() []attribute.KeyValue {
+	return Attributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s scrubbedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	out := make([]sdktrace.Event, len(events))
+	for i, e := range events {
+		e.Name = String(e.Name)
+		e.Attributes = Attributes(e.Attributes)
+		out[i] = e
+	}
+	return out
+}
+
+// Attributes returns a copy of attrs with IDNs redacted.
+func Attributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		out[i] = Attribute(kv)
+	}
+	return out
+}
+
+// Attribute redacts a single attribute. Raw values of the known IDN keys
+// are replaced whatever their length; other string values have IDN-like
+// runs replaced.
+func Attribute(kv attribute.KeyValue) attribute.KeyValue {
+	switch kv.Value.Type() {
+	case attribute.STRING:
+		v := kv.Value.AsString()
+		// Values already passed through IDN are left alone, so hashes
+		// stay stable.
+		if sensitiveKeys[kv.Key] && digitRun.FindString(v) == v {
+			return kv.Key.String(IDN(v))
+		}
+		return kv.Key.String(String(v))
+	case attribute.STRINGSLICE:
+		vs := kv.Value.AsStringSlice()
+		for i, v := range vs {
+			vs[i] = String(v)
+		}
+		return kv.Key.StringSlice(vs)
+	}
+	return kv
+}
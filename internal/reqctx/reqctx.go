@@ -0,0 +1,186 @@
+// Package reqctx carries business context of a request — tenant, channel
+// and request ID — between services in OpenTelemetry baggage.
+//
+// shipment-service sets the baggage at its edges, Middleware for HTTP and
+// EdgeUnaryServerInterceptor for gRPC; the otelgrpc client sends it along
+// with the trace context, and the request ID also goes as x-request-id
+// metadata. On the internal hop to customer-service the interceptors trust
+// the incoming baggage and copy the members into span attributes and log
+// attributes, so a trace or log line can be filtered by tenant or channel.
+package reqctx
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"testovoe/internal/logging"
+)
+
+// Baggage keys; they double as span and log attribute names.
+const (
+	KeyTenant    = "tenant"
+	KeyChannel   = "channel"
+	KeyRequestID = "request_id"
+)
+
+// Request headers read by Middleware.
+const (
+	HeaderTenant    = "X-Tenant-ID"
+	HeaderChannel   = "X-Channel"
+	HeaderRequestID = "X-Request-ID"
+)
+
+// gRPC metadata read by EdgeUnaryServerInterceptor, besides
+// MetadataRequestID.
+const (
+	MetadataTenant  = "x-tenant-id"
+	MetadataChannel = "x-channel"
+)
+
+// maxValueLen caps values taken from client headers.
+const maxValueLen = 128
+
+var keys = []string{KeyTenant, KeyChannel, KeyRequestID}
+
+// Middleware puts the tenant, channel and request ID headers into the
+// request baggage and annotates the request span and logs with them. A
+// missing or malformed X-Request-ID is replaced with a generated one. The
+// request ID, X-Trace-ID and traceparent are returned as response headers.
+// Values for these keys sent by the client as baggage are discarded: only
+// the edge decides them. It must run inside the tracing middleware.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r.Header.Get(HeaderRequestID))
+		ctx := atEdge(r.Context(), r.Header.Get(HeaderTenant), r.Header.Get(HeaderChannel), id)
+		setResponseHeaders(ctx, w.Header(), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// EdgeUnaryServerInterceptor is Middleware for a gRPC API called from
+// outside: tenant, channel and request ID come from the x-tenant-id,
+// x-channel and x-request-id metadata, and baggage members for them sent
+// by the client are discarded. The request ID is returned as x-request-id
+// header metadata. It must come after the otelgrpc interceptor.
+func EdgeUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		first := func(key string) string {
+			if v := md.Get(key); len(v) > 0 {
+				return v[0]
+			}
+			return ""
+		}
+
+		id := requestID(first(MetadataRequestID))
+		ctx = atEdge(ctx, first(MetadataTenant), first(MetadataChannel), id)
+		grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, id))
+		return handler(ctx, req)
+	}
+}
+
+// atEdge replaces the tenant, channel and request ID baggage of ctx with
+// the given values and annotates the span and logs with them.
+func atEdge(ctx context.Context, tenant, channel, id string) context.Context {
+	b := baggage.FromContext(ctx)
+	for _, k := range keys {
+		b = b.DeleteMember(k)
+	}
+	b = withMember(b, KeyTenant, tenant)
+	b = withMember(b, KeyChannel, channel)
+	b = withMember(b, KeyRequestID, id)
+	return Annotate(baggage.ContextWithBaggage(ctx, b))
+}
+
+// withMember returns b with key set to value; empty or invalid values
+// leave b unchanged. Values longer than maxValueLen bytes are cut at a
+// rune boundary so that they stay valid UTF-8.
+func withMember(b baggage.Baggage, key, value string) baggage.Baggage {
+	if value == "" {
+		return b
+	}
+	if len(value) > maxValueLen {
+		n := maxValueLen
+		for n > 0 && !utf8.RuneStart(value[n]) {
+			n--
+		}
+		value = value[:n]
+	}
+	m, err := baggage.NewMemberRaw(key, value)
+	if err != nil {
+		return b
+	}
+	if out, err := b.SetMember(m); err == nil {
+		return out
+	}
+	return b
+}
+
+// Annotate copies the tenant, channel and request ID baggage members of
+// ctx onto the current span and returns a context whose log records carry
+// them as well.
+func Annotate(ctx context.Context) context.Context {
+	b := baggage.FromContext(ctx)
+
+	var (
+		spanAttrs []attribute.KeyValue
+		logAttrs  []slog.Attr
+	)
+	for _, k := range keys {
+		v := b.Member(k).Value()
+		if v == "" {
+			continue
+		}
+		spanAttrs = append(spanAttrs, attribute.String(k, v))
+		logAttrs = append(logAttrs, slog.String(k, v))
+	}
+	if len(spanAttrs) == 0 {
+		return ctx
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(spanAttrs...)
+	return logging.WithAttrs(ctx, logAttrs...)
+}
+
+// UnaryServerInterceptor annotates each call with the incoming baggage,
+// taking the request ID from x-request-id metadata when the baggage has
+// none. The baggage is trusted, so it is for internal services such as
+// customer-service, called only by shipment-service; a gRPC API called from
+// outside uses EdgeUnaryServerInterceptor. It must come after the otelgrpc
+// interceptor, which extracts the baggage and starts the server span.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = withRequestID(ctx, incomingRequestID(ctx))
+		return handler(Annotate(ctx), req)
+	}
+}
+
+// ConnectInterceptor is UnaryServerInterceptor for Connect and gRPC-Web
+// handlers, whose baggage is extracted by the otelhttp wrapper.
+func ConnectInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			ctx = withRequestID(ctx, req.Header().Get(HeaderRequestID))
+			return next(Annotate(ctx), req)
+		}
+	}
+}
+
+// GatewayMiddleware is UnaryServerInterceptor for the REST gateway, which
+// calls the gRPC methods in-process without running the interceptors.
+func GatewayMiddleware(next runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx := withRequestID(r.Context(), r.Header.Get(HeaderRequestID))
+		next(w, r.WithContext(Annotate(ctx)), pathParams)
+	}
+}
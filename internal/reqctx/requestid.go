@@ -0,0 +1,90 @@
+package reqctx
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// HeaderTraceID returns the trace ID of the request, so clients need not
+// parse traceparent to quote it.
+const HeaderTraceID = "X-Trace-ID"
+
+// MetadataRequestID is the gRPC metadata key the request ID is forwarded
+// under; Envoy and customer-service read the same key.
+const MetadataRequestID = "x-request-id"
+
+// RequestID returns the request ID carried by ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	return baggage.FromContext(ctx).Member(KeyRequestID).Value()
+}
+
+// requestID returns the client's request ID if it is usable, otherwise a
+// new one.
+func requestID(id string) string {
+	if validRequestID(id) {
+		return id
+	}
+	return uuid.NewString()
+}
+
+// validRequestID accepts up to maxValueLen printable ASCII characters, so
+// a client-supplied ID is safe to echo in headers and logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxValueLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// setResponseHeaders returns the request ID and the trace context of the
+// request span to the client. They are set before the handler runs, so
+// they are present on every response, errors included.
+func setResponseHeaders(ctx context.Context, h http.Header, id string) {
+	h.Set(HeaderRequestID, id)
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(HeaderTraceID, sc.TraceID().String())
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(h))
+}
+
+// UnaryClientInterceptor forwards the request ID of ctx as gRPC metadata.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataRequestID, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// withRequestID adds id to the baggage of ctx unless it already carries a
+// request ID, which takes precedence.
+func withRequestID(ctx context.Context, id string) context.Context {
+	if !validRequestID(id) || RequestID(ctx) != "" {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, withMember(baggage.FromContext(ctx), KeyRequestID, id))
+}
+
+// incomingRequestID returns the request ID sent as gRPC metadata.
+func incomingRequestID(ctx context.Context) string {
+	if v := metadata.ValueFromIncomingContext(ctx, MetadataRequestID); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+// Package customersync keeps the local customer_refs table in step with
+// customer-service by reading its customer event feed.
+package customersync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	pb "testovoe/api/proto"
+	"testovoe/internal/shipment/repo"
+)
+
+const batchSize = 500
+
+// Store holds the customer references and the feed position.
+type Store interface {
+	CustomerSyncPosition(ctx context.Context) (int64, error)
+	ApplyCustomerRefs(ctx context.Context, refs []*repo.CustomerRef, sequence int64) error
+}
+
+// Feed reads customer events from customer-service.
+type Feed interface {
+	ListCustomerEvents(ctx context.Context, after int64, limit int32) ([]*pb.CustomerEvent, error)
+}
+
+type Syncer struct {
+	repo     Store
+	client   Feed
+	interval time.Duration
+}
+
+// NewSyncer returns a Syncer that polls the feed every interval once it
+// has caught up.
+func NewSyncer(repo Store, client Feed, interval time.Duration) *Syncer {
+	return &Syncer{repo: repo, client: client, interval: interval}
+}
+
+// Run applies customer events until ctx is cancelled. Errors are logged and
+// retried on the next tick; the position is only advanced after a batch is
+// stored, so no event is lost.
+func (s *Syncer) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		n, err := s.SyncOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Customer sync failed", "error", err)
+		}
+
+		// A full batch means there is probably more to read right away.
+		next := s.interval
+		if err == nil && n == batchSize {
+			next = 0
+		}
+		timer.Reset(next)
+	}
+}
+
+// SyncOnce applies one batch of events and returns how many were applied.
+func (s *Syncer) SyncOnce(ctx context.Context) (int, error) {
+	ctx, span := otel.Tracer("shipment-service").Start(ctx, "SyncCustomers")
+	defer span.End()
+
+	after, err := s.repo.CustomerSyncPosition(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	span.SetAttributes(attribute.Int64("customer.events.after_sequence", after))
+
+	events, err := s.client.ListCustomerEvents(ctx, after, batchSize)
+	if err != nil {
+		span.RecordError(err)
+		return 0, fmt.Errorf("failed to list customer events: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	refs := make([]*repo.CustomerRef, 0, len(events))
+	for _, e := range events {
+		ref, err := ToCustomerRef(e.Customer)
+		if err != nil {
+			span.RecordError(err)
+			return 0, fmt.Errorf("customer event %d: %w", e.Sequence, err)
+		}
+		refs = append(refs, ref)
+	}
+
+	last := events[len(events)-1].Sequence
+	if err := s.repo.ApplyCustomerRefs(ctx, refs, last); err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+
+	span.SetAttributes(attribute.Int("customer.events.count", len(events)))
+	return len(events), nil
+}
+
+// ToCustomerRef converts a customer as returned by customer-service into a
+// local reference.
+func ToCustomerRef(c *pb.CustomerResponse) (*repo.CustomerRef, error) {
+	if c == nil {
+		return nil, fmt.Errorf("missing customer")
+	}
+	createdAt, err := time.Parse(time.RFC3339, c.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid created_at %q: %w", c.CreatedAt, err)
+	}
+	return &repo.CustomerRef{ID: c.Id, IDN: c.Idn, CreatedAt: createdAt}, nil
+}
@@ -3,46 +3,130 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/credentials/insecure"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 
 	pb "testovoe/api/proto"
+	"testovoe/internal/reqctx"
 )
 
 type Client struct {
 	conn   *grpc.ClientConn
 	client pb.CustomerServiceClient
+	retry  atomic.Pointer[RetryPolicy]
 }
 
-func NewClient(endpoint string) (*Client, error) {
-	conn, err := grpc.NewClient(
-		endpoint,
+// RetryPolicy controls how calls that fail with UNAVAILABLE are retried.
+// All RPCs are idempotent, so retrying them is safe.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var defaultRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// NewClient creates a client for the customer-service at endpoint. opts are
+// appended to the defaults, e.g. to dial an in-process listener in tests.
+func NewClient(endpoint string, opts ...grpc.DialOption) (*Client, error) {
+	dialOpts := append([]grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
-	)
+		grpc.WithChainUnaryInterceptor(
+			otelgrpc.UnaryClientInterceptor(),
+			reqctx.UnaryClientInterceptor(),
+		),
+	}, opts...)
+
+	conn, err := grpc.NewClient(endpoint, dialOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to customer service: %w", err)
 	}
 
-	return &Client{
+	c := &Client{
 		conn:   conn,
 		client: pb.NewCustomerServiceClient(conn),
-	}, nil
+	}
+	c.retry.Store(&defaultRetryPolicy)
+	return c, nil
+}
+
+// SetRetryPolicy replaces the retry policy; calls already in progress keep
+// the policy they started with.
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c.retry.Store(&p)
 }
 
 func (c *Client) UpsertCustomer(ctx context.Context, idn string) (*pb.CustomerResponse, error) {
 	req := &pb.UpsertCustomerRequest{Idn: idn}
-	return c.client.UpsertCustomer(ctx, req)
+	return withRetry(ctx, c.retry.Load(), func(ctx context.Context) (*pb.CustomerResponse, error) {
+		return c.client.UpsertCustomer(ctx, req)
+	})
 }
 
 func (c *Client) GetCustomer(ctx context.Context, idn string) (*pb.CustomerResponse, error) {
 	req := &pb.GetCustomerRequest{Idn: idn}
-	return c.client.GetCustomer(ctx, req)
+	return withRetry(ctx, c.retry.Load(), func(ctx context.Context) (*pb.CustomerResponse, error) {
+		return c.client.GetCustomer(ctx, req)
+	})
+}
+
+// ListCustomerEvents fetches up to limit customer events after the given
+// sequence.
+func (c *Client) ListCustomerEvents(ctx context.Context, after int64, limit int32) ([]*pb.CustomerEvent, error) {
+	req := &pb.ListCustomerEventsRequest{AfterSequence: after, Limit: limit}
+	resp, err := withRetry(ctx, c.retry.Load(), func(ctx context.Context) (*pb.ListCustomerEventsResponse, error) {
+		return c.client.ListCustomerEvents(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
+
+func withRetry[T any](ctx context.Context, policy *RetryPolicy, call func(context.Context) (T, error)) (T, error) {
+	backoff := policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		resp, err := call(ctx)
+		if err == nil || attempt >= policy.MaxAttempts || status.Code(err) != codes.Unavailable {
+			return resp, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return resp, err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
 }
 
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
+// CheckConnection reports an error when the channel to customer-service is
+// failing. An idle channel is asked to connect and counts as healthy, since
+// it only dials on the first RPC.
+func (c *Client) CheckConnection(ctx context.Context) error {
+	state := c.conn.GetState()
+	switch state {
+	case connectivity.Idle:
+		c.conn.Connect()
+		return nil
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return fmt.Errorf("customer service channel is %s", state)
+	}
+	return nil
+}
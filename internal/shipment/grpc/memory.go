@@ -0,0 +1,95 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "testovoe/api/proto"
+)
+
+// MemoryClient stands in for customer-service without a network: customers
+// and their events live in memory. It is safe for concurrent use and meant
+// for demos and tests. Unlike customer-service it does not validate IDNs.
+type MemoryClient struct {
+	mu     sync.RWMutex
+	byIDN  map[string]*pb.CustomerResponse
+	events []*pb.CustomerEvent
+}
+
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{byIDN: make(map[string]*pb.CustomerResponse)}
+}
+
+func (c *MemoryClient) UpsertCustomer(ctx context.Context, idn string) (*pb.CustomerResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if customer, ok := c.byIDN[idn]; ok {
+		return cloneCustomer(customer), nil
+	}
+
+	now := time.Now()
+	customer := &pb.CustomerResponse{
+		Id:        uuid.New().String(),
+		Idn:       idn,
+		CreatedAt: now.Format(time.RFC3339),
+	}
+	c.byIDN[idn] = customer
+	c.events = append(c.events, &pb.CustomerEvent{
+		Sequence:   int64(len(c.events) + 1),
+		Type:       "CUSTOMER_CREATED",
+		Customer:   cloneCustomer(customer),
+		OccurredAt: now.Format(time.RFC3339),
+	})
+
+	return cloneCustomer(customer), nil
+}
+
+func (c *MemoryClient) GetCustomer(ctx context.Context, idn string) (*pb.CustomerResponse, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	customer, ok := c.byIDN[idn]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "customer not found")
+	}
+	return cloneCustomer(customer), nil
+}
+
+func (c *MemoryClient) ListCustomerEvents(ctx context.Context, after int64, limit int32) ([]*pb.CustomerEvent, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if after < 0 {
+		after = 0
+	}
+	var events []*pb.CustomerEvent
+	for i := after; i < int64(len(c.events)) && int32(len(events)) < limit; i++ {
+		e := c.events[i]
+		events = append(events, &pb.CustomerEvent{
+			Sequence:   e.Sequence,
+			Type:       e.Type,
+			Customer:   cloneCustomer(e.Customer),
+			OccurredAt: e.OccurredAt,
+		})
+	}
+	return events, nil
+}
+
+// CheckConnection always succeeds.
+func (c *MemoryClient) CheckConnection(ctx context.Context) error {
+	return nil
+}
+
+func (c *MemoryClient) Close() error {
+	return nil
+}
+
+func cloneCustomer(c *pb.CustomerResponse) *pb.CustomerResponse {
+	return &pb.CustomerResponse{Id: c.Id, Idn: c.Idn, CreatedAt: c.CreatedAt}
+}
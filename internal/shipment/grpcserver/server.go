@@ -0,0 +1,269 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	pb "testovoe/api/proto"
+	"testovoe/internal/auth"
+	healthprobe "testovoe/internal/health"
+	"testovoe/internal/metrics"
+	"testovoe/internal/ratelimit"
+	"testovoe/internal/reqctx"
+	"testovoe/internal/shipment/repo"
+	"testovoe/internal/shipment/service"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+type Server struct {
+	pb.UnimplementedShipmentServiceServer
+	service *service.Service
+}
+
+func NewServer(svc *service.Service) *Server {
+	return &Server{service: svc}
+}
+
+func (s *Server) CreateShipment(ctx context.Context, req *pb.CreateShipmentRequest) (*pb.ShipmentResponse, error) {
+	ctx, span := otel.Tracer("shipment-grpc").Start(ctx, "CreateShipment")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("grpc.method", "CreateShipment"))
+
+	var createReq service.CreateShipmentRequest
+	createReq.Route = req.Route
+	createReq.Price = req.Price
+	createReq.Customer.IDN = req.CustomerIdn
+
+	shipment, err := s.service.CreateShipment(ctx, createReq)
+	if err != nil {
+		span.RecordError(err)
+		return nil, toStatus(err, "failed to create shipment")
+	}
+
+	slog.InfoContext(ctx, "Created shipment", "shipment.id", shipment.ID)
+
+	return toResponse(shipment), nil
+}
+
+func (s *Server) GetShipment(ctx context.Context, req *pb.GetShipmentRequest) (*pb.ShipmentResponse, error) {
+	ctx, span := otel.Tracer("shipment-grpc").Start(ctx, "GetShipment")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("grpc.method", "GetShipment"),
+		attribute.String("shipment.id", req.Id),
+	)
+
+	shipment, err := s.service.GetShipment(ctx, req.Id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, toStatus(err, "failed to get shipment")
+	}
+
+	slog.InfoContext(ctx, "Retrieved shipment", "shipment.id", shipment.ID)
+
+	return toResponse(shipment), nil
+}
+
+func (s *Server) ListShipments(ctx context.Context, req *pb.ListShipmentsRequest) (*pb.ListShipmentsResponse, error) {
+	ctx, span := otel.Tracer("shipment-grpc").Start(ctx, "ListShipments")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("grpc.method", "ListShipments"))
+
+	shipments, err := s.service.ListShipments(ctx, service.ListShipmentsRequest{
+		CustomerID: req.CustomerId,
+		Status:     req.Status,
+		Limit:      int(req.Limit),
+		Offset:     int(req.Offset),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, toStatus(err, "failed to list shipments")
+	}
+
+	resp := &pb.ListShipmentsResponse{
+		Shipments: make([]*pb.ShipmentResponse, 0, len(shipments)),
+	}
+	for _, shipment := range shipments {
+		resp.Shipments = append(resp.Shipments, toResponse(shipment))
+	}
+
+	return resp, nil
+}
+
+func (s *Server) TransitionShipment(ctx context.Context, req *pb.TransitionShipmentRequest) (*pb.ShipmentResponse, error) {
+	ctx, span := otel.Tracer("shipment-grpc").Start(ctx, "TransitionShipment")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("grpc.method", "TransitionShipment"),
+		attribute.String("shipment.id", req.Id),
+		attribute.String("shipment.status.to", req.Status),
+	)
+
+	shipment, err := s.service.TransitionShipment(ctx, req.Id, req.Status)
+	if err != nil {
+		span.RecordError(err)
+		return nil, toStatus(err, "failed to transition shipment")
+	}
+
+	slog.InfoContext(ctx, "Transitioned shipment", "shipment.id", shipment.ID, "shipment.status", shipment.Status)
+
+	return toResponse(shipment), nil
+}
+
+func toStatus(err error, msg string) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidRequest):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidTransition):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, repo.ErrNotFound):
+		return status.Error(codes.NotFound, "shipment not found")
+	case errors.Is(err, repo.ErrStatusChanged):
+		return status.Error(codes.Aborted, err.Error())
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
+
+func toResponse(shipment *repo.Shipment) *pb.ShipmentResponse {
+	return &pb.ShipmentResponse{
+		Id:         shipment.ID,
+		Route:      shipment.Route,
+		Price:      shipment.Price,
+		Status:     shipment.Status,
+		CustomerId: shipment.CustomerID,
+		CreatedAt:  shipment.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+type Options struct {
+	Port string
+	// HealthInterval is how often the store is pinged to update the
+	// grpc.health.v1 status.
+	HealthInterval time.Duration
+	// RateLimiter, if set, limits calls.
+	RateLimiter *ratelimit.Limiter
+	// Authenticator, if set, requires a bearer token on ShipmentService
+	// calls.
+	Authenticator *auth.Authenticator
+}
+
+// readOnlyMethods need only the read scope; the rest change shipments.
+var readOnlyMethods = map[string]bool{
+	pb.ShipmentService_GetShipment_FullMethodName:   true,
+	pb.ShipmentService_ListShipments_FullMethodName: true,
+}
+
+func isReadOnly(fullMethod string) bool {
+	return readOnlyMethods[fullMethod]
+}
+
+// GRPCServer is a running shipment gRPC server. It is returned by
+// StartGRPCServer so the caller can stop it.
+type GRPCServer struct {
+	grpcServer *grpc.Server
+	addr       net.Addr
+	health     *health.Server
+	stopProbe  context.CancelFunc
+	errCh      chan error
+}
+
+// StartGRPCServer listens on opts.Port and serves ShipmentService and
+// grpc.health.v1 in the background. Serve errors are delivered on Err.
+func StartGRPCServer(ctx context.Context, opts Options, svc *service.Service, db healthprobe.Pinger) (*GRPCServer, error) {
+	lis, err := net.Listen("tcp", ":"+opts.Port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	interceptors := []grpc.UnaryServerInterceptor{
+		otelgrpc.UnaryServerInterceptor(otelgrpc.WithMeterProvider(noop.NewMeterProvider())),
+		metrics.UnaryServerInterceptor(),
+		reqctx.EdgeUnaryServerInterceptor(),
+		ratelimit.UnaryServerInterceptor(opts.RateLimiter),
+	}
+	if opts.Authenticator != nil {
+		interceptors = append(interceptors, opts.Authenticator.UnaryServerInterceptor(isReadOnly))
+	}
+
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+
+	pb.RegisterShipmentServiceServer(s, NewServer(svc))
+
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+
+	probeCtx, stopProbe := context.WithCancel(ctx)
+	probe := healthprobe.NewProbe(hs, opts.HealthInterval, pb.ShipmentService_ServiceDesc.ServiceName)
+	probe.AddCheck("database", db.Ping)
+	probe.Run(probeCtx)
+
+	srv := &GRPCServer{
+		grpcServer: s,
+		addr:       lis.Addr(),
+		health:     hs,
+		stopProbe:  stopProbe,
+		errCh:      make(chan error, 1),
+	}
+
+	go func() {
+		slog.Info("Shipment gRPC server listening", "port", opts.Port)
+		if err := s.Serve(lis); err != nil {
+			srv.errCh <- err
+		}
+		close(srv.errCh)
+	}()
+
+	return srv, nil
+}
+
+// Addr returns the address the server listens on, which tells the port
+// when Options.Port is "0".
+func (s *GRPCServer) Addr() net.Addr {
+	return s.addr
+}
+
+// Err returns a channel that receives the error that made the server stop
+// serving unexpectedly. It is closed once serving ends.
+func (s *GRPCServer) Err() <-chan error {
+	return s.errCh
+}
+
+// Shutdown marks the server NOT_SERVING, stops accepting connections and
+// waits for in-flight RPCs until ctx is done. RPCs still running then are
+// cancelled, so main can bound the HTTP and gRPC drain with one deadline.
+func (s *GRPCServer) Shutdown(ctx context.Context) {
+	s.stopProbe()
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("gRPC server did not drain in time, closing connections")
+		s.grpcServer.Stop()
+		<-done
+	}
+}
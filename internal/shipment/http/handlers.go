@@ -2,7 +2,8 @@ package http
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -10,6 +11,8 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 
+	"testovoe/internal/problem"
+	"testovoe/internal/shipment/repo"
 	"testovoe/internal/shipment/service"
 )
 
@@ -21,6 +24,13 @@ func NewHandler(svc *service.Service) *Handler {
 	return &Handler{service: svc}
 }
 
+// RegisterRoutes adds the shipment endpoints to r, which is expected to be
+// mounted at /api/v1.
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/shipments", h.CreateShipment).Methods("POST")
+	r.HandleFunc("/shipments/{id}", h.GetShipment).Methods("GET")
+}
+
 type CreateShipmentRequest struct {
 	Route    string  `json:"route"`
 	Price    float64 `json:"price"`
@@ -48,7 +58,7 @@ func (h *Handler) CreateShipment(w http.ResponseWriter, r *http.Request) {
 	var req CreateShipmentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		span.RecordError(err)
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
@@ -60,7 +70,11 @@ func (h *Handler) CreateShipment(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		span.RecordError(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, service.ErrInvalidRequest) {
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		problem.Write(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -77,10 +91,7 @@ func (h *Handler) CreateShipment(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 
-	if spanCtx := span.SpanContext(); spanCtx.IsValid() {
-		traceID := spanCtx.TraceID().String()
-		log.Printf("Created shipment %s, trace_id: %s", shipment.ID, traceID)
-	}
+	slog.InfoContext(ctx, "Created shipment", "shipment.id", shipment.ID)
 }
 
 func (h *Handler) GetShipment(w http.ResponseWriter, r *http.Request) {
@@ -99,11 +110,11 @@ func (h *Handler) GetShipment(w http.ResponseWriter, r *http.Request) {
 	shipment, err := h.service.GetShipment(ctx, id)
 	if err != nil {
 		span.RecordError(err)
-		if err.Error() == "shipment not found" {
-			http.Error(w, "shipment not found", http.StatusNotFound)
+		if errors.Is(err, repo.ErrNotFound) {
+			problem.Write(w, r, http.StatusNotFound, "shipment not found")
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -119,8 +130,5 @@ func (h *Handler) GetShipment(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 
-	if spanCtx := span.SpanContext(); spanCtx.IsValid() {
-		traceID := spanCtx.TraceID().String()
-		log.Printf("Retrieved shipment %s, trace_id: %s", shipment.ID, traceID)
-	}
+	slog.InfoContext(ctx, "Retrieved shipment", "shipment.id", shipment.ID)
 }
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable. A nil error means healthy.
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status   string                 `json:"status"`
+	Draining bool                   `json:"draining"`
+	Checks   map[string]CheckResult `json:"checks"`
+}
+
+// HealthHandler serves /healthz and /readyz. Liveness only says the process
+// is up; readiness runs every registered dependency check and fails while
+// the service is draining.
+type HealthHandler struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	draining atomic.Bool
+}
+
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{checks: make(map[string]Check)}
+}
+
+func (h *HealthHandler) AddCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// SetDraining flips readiness to failing so load balancers stop routing
+// new traffic before the server shuts down.
+func (h *HealthHandler) SetDraining(draining bool) {
+	h.draining.Store(draining)
+}
+
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+	defer cancel()
+
+	h.mu.RLock()
+	checks := make(map[string]Check, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.mu.RUnlock()
+
+	response := ReadinessResponse{
+		Status:   "ok",
+		Draining: h.draining.Load(),
+		Checks:   make(map[string]CheckResult, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			result := CheckResult{
+				Status:    "ok",
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			response.Checks[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, result := range response.Checks {
+		if result.Status != "ok" {
+			response.Status = "fail"
+		}
+	}
+	if response.Draining {
+		response.Status = "fail"
+	}
+	if response.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
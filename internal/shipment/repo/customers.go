@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrCustomerNotFound = errors.New("customer reference not found")
+
+// CustomerRef is the local copy of a customer owned by customer-service.
+type CustomerRef struct {
+	ID        string
+	IDN       string
+	CreatedAt time.Time
+}
+
+const upsertCustomerRefQuery = `INSERT INTO customer_refs (id, idn, created_at) VALUES ($1, $2, $3)
+	ON CONFLICT (id) DO UPDATE SET idn = EXCLUDED.idn, created_at = EXCLUDED.created_at, synced_at = now()`
+
+func (r *Repository) GetCustomerRefByIDN(ctx context.Context, idn string) (*CustomerRef, error) {
+	var ref CustomerRef
+	query := `SELECT id, idn, created_at FROM customer_refs WHERE idn = $1`
+	err := r.db.QueryRow(ctx, query, idn).Scan(
+		&ref.ID,
+		&ref.IDN,
+		&ref.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCustomerNotFound
+		}
+		return nil, fmt.Errorf("failed to query customer reference: %w", err)
+	}
+
+	return &ref, nil
+}
+
+// SaveCustomerRef stores ref as seen in a customer-service response, so a
+// new customer is known locally before its event arrives.
+func (r *Repository) SaveCustomerRef(ctx context.Context, ref *CustomerRef) error {
+	if _, err := r.db.Exec(ctx, upsertCustomerRefQuery, ref.ID, ref.IDN, ref.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save customer reference: %w", err)
+	}
+	return nil
+}
+
+// CustomerSyncPosition returns the sequence of the last applied customer
+// event.
+func (r *Repository) CustomerSyncPosition(ctx context.Context) (int64, error) {
+	var sequence int64
+	err := r.db.QueryRow(ctx, `SELECT last_sequence FROM customer_sync WHERE id`).Scan(&sequence)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read customer sync position: %w", err)
+	}
+	return sequence, nil
+}
+
+// ApplyCustomerRefs stores refs and advances the sync position to sequence
+// in one transaction, so a crash never records events as applied without
+// their data.
+func (r *Repository) ApplyCustomerRefs(ctx context.Context, refs []*CustomerRef, sequence int64) error {
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		for _, ref := range refs {
+			if _, err := tx.Exec(ctx, upsertCustomerRefQuery, ref.ID, ref.IDN, ref.CreatedAt); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(ctx, `UPDATE customer_sync SET last_sequence = $1 WHERE id AND last_sequence < $1`, sequence)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply customer references: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"testovoe/internal/audit"
+)
+
+// MemoryRepository keeps shipments and customer references in memory. It
+// is safe for concurrent use and meant for demos and tests; data is lost on
+// restart.
+type MemoryRepository struct {
+	mu           sync.RWMutex
+	shipments    map[string]*Shipment
+	customers    map[string]*CustomerRef
+	syncPosition int64
+	audit        audit.MemoryLog
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		shipments: make(map[string]*Shipment),
+		customers: make(map[string]*CustomerRef),
+	}
+}
+
+func (r *MemoryRepository) CreateShipment(ctx context.Context, shipment *Shipment) error {
+	if shipment.ID == "" {
+		shipment.ID = uuid.New().String()
+	}
+	if shipment.Status == "" {
+		shipment.Status = StatusCreated
+	}
+	if shipment.CreatedAt.IsZero() {
+		shipment.CreatedAt = time.Now()
+	}
+
+	entry, err := newCreatedEntry(ctx, shipment)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *shipment
+	r.shipments[shipment.ID] = &stored
+	r.audit.Append(entry)
+	return nil
+}
+
+func (r *MemoryRepository) GetShipment(ctx context.Context, id string) (*Shipment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.shipments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	shipment := *s
+	return &shipment, nil
+}
+
+func (r *MemoryRepository) ListShipments(ctx context.Context, filter ListFilter) ([]*Shipment, error) {
+	r.mu.RLock()
+	var matched []*Shipment
+	for _, s := range r.shipments {
+		if filter.CustomerID != "" && s.CustomerID != filter.CustomerID {
+			continue
+		}
+		if filter.Status != "" && s.Status != filter.Status {
+			continue
+		}
+		shipment := *s
+		matched = append(matched, &shipment)
+	}
+	r.mu.RUnlock()
+
+	// Same order as the Postgres query: newest first, then by id.
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].ID < matched[j].ID
+	})
+
+	if filter.Offset >= len(matched) {
+		return nil, nil
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	return matched, nil
+}
+
+func (r *MemoryRepository) UpdateShipmentStatus(ctx context.Context, id, from, to string) error {
+	entry, err := newStatusEntry(ctx, id, from, to)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.shipments[id]
+	if !ok || s.Status != from {
+		return ErrStatusChanged
+	}
+	s.Status = to
+	r.audit.Append(entry)
+	return nil
+}
+
+func (r *MemoryRepository) ListAudit(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error) {
+	return r.audit.List(filter), nil
+}
+
+func (r *MemoryRepository) GetCustomerRefByIDN(ctx context.Context, idn string) (*CustomerRef, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.customers {
+		if c.IDN == idn {
+			ref := *c
+			return &ref, nil
+		}
+	}
+	return nil, ErrCustomerNotFound
+}
+
+func (r *MemoryRepository) SaveCustomerRef(ctx context.Context, ref *CustomerRef) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *ref
+	r.customers[ref.ID] = &stored
+	return nil
+}
+
+func (r *MemoryRepository) CustomerSyncPosition(ctx context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.syncPosition, nil
+}
+
+func (r *MemoryRepository) ApplyCustomerRefs(ctx context.Context, refs []*CustomerRef, sequence int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, ref := range refs {
+		stored := *ref
+		r.customers[ref.ID] = &stored
+	}
+	if sequence > r.syncPosition {
+		r.syncPosition = sequence
+	}
+	return nil
+}
+
+// Ping always succeeds; it lets the repository stand in for the database
+// in health checks.
+func (r *MemoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
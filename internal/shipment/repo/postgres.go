@@ -2,88 +2,100 @@ package repo
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"testovoe/internal/audit"
+)
+
+const (
+	StatusCreated   = "CREATED"
+	StatusInTransit = "IN_TRANSIT"
+	StatusDelivered = "DELIVERED"
+	StatusCancelled = "CANCELLED"
+)
+
+var (
+	ErrNotFound      = errors.New("shipment not found")
+	ErrStatusChanged = errors.New("shipment status changed concurrently")
 )
 
 type Shipment struct {
-	ID         string
-	Route      string
-	Price      float64
-	Status     string
+	ID         string    `json:"id"`
+	Route      string    `json:"route"`
+	Price      float64   `json:"price"`
+	Status     string    `json:"status"`
+	CustomerID string    `json:"customer_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ListFilter struct {
 	CustomerID string
-	CreatedAt  time.Time
+	Status     string
+	Limit      int
+	Offset     int
 }
 
 type Repository struct {
-	db *sql.DB
+	db *pgxpool.Pool
 }
 
-func NewRepository(db *sql.DB) *Repository {
+func NewRepository(db *pgxpool.Pool) *Repository {
 	return &Repository{db: db}
 }
 
 func (r *Repository) CreateShipment(ctx context.Context, shipment *Shipment) error {
-	ctx, span := otel.Tracer("shipment-repo").Start(ctx, "CreateShipment")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("db.operation", "insert"),
-		attribute.String("shipment.route", shipment.Route),
-		attribute.String("shipment.customer_id", shipment.CustomerID),
-	)
-
 	if shipment.ID == "" {
 		shipment.ID = uuid.New().String()
 	}
 	if shipment.Status == "" {
-		shipment.Status = "CREATED"
+		shipment.Status = StatusCreated
 	}
 	if shipment.CreatedAt.IsZero() {
 		shipment.CreatedAt = time.Now()
 	}
 
+	entry, err := newCreatedEntry(ctx, shipment)
+	if err != nil {
+		return err
+	}
+
 	query := `INSERT INTO shipments (id, route, price, status, customer_id, created_at) 
 		VALUES ($1, $2, $3, $4, $5, $6)`
 	
-	_, err := r.db.ExecContext(ctx, query,
-		shipment.ID,
-		shipment.Route,
-		shipment.Price,
-		shipment.Status,
-		shipment.CustomerID,
-		shipment.CreatedAt,
-	)
+	err = pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, query,
+			shipment.ID,
+			shipment.Route,
+			shipment.Price,
+			shipment.Status,
+			shipment.CustomerID,
+			shipment.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return audit.AppendPostgres(ctx, tx, entry)
+	})
 
 	if err != nil {
-		span.RecordError(err)
 		return fmt.Errorf("failed to insert shipment: %w", err)
 	}
 
-	span.SetAttributes(attribute.String("db.result", "created"))
 	return nil
 }
 
 func (r *Repository) GetShipment(ctx context.Context, id string) (*Shipment, error) {
-	ctx, span := otel.Tracer("shipment-repo").Start(ctx, "GetShipment")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("db.operation", "select"),
-		attribute.String("shipment.id", id),
-	)
-
 	var shipment Shipment
 	query := `SELECT id, route, price, status, customer_id, created_at 
 		FROM shipments WHERE id = $1`
 	
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := r.db.QueryRow(ctx, query, id).Scan(
 		&shipment.ID,
 		&shipment.Route,
 		&shipment.Price,
@@ -93,15 +105,94 @@ func (r *Repository) GetShipment(ctx context.Context, id string) (*Shipment, err
 	)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			span.SetAttributes(attribute.String("db.result", "not_found"))
-			return nil, fmt.Errorf("shipment not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
 		}
-		span.RecordError(err)
 		return nil, fmt.Errorf("failed to query shipment: %w", err)
 	}
 
-	span.SetAttributes(attribute.String("db.result", "found"))
 	return &shipment, nil
 }
 
+func (r *Repository) ListShipments(ctx context.Context, filter ListFilter) ([]*Shipment, error) {
+	query := `SELECT id, route, price, status, customer_id, created_at 
+		FROM shipments
+		WHERE ($1 = '' OR customer_id::text = $1) AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id
+		LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.Query(ctx, query, filter.CustomerID, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shipments: %w", err)
+	}
+	defer rows.Close()
+
+	var shipments []*Shipment
+	for rows.Next() {
+		var shipment Shipment
+		if err := rows.Scan(
+			&shipment.ID,
+			&shipment.Route,
+			&shipment.Price,
+			&shipment.Status,
+			&shipment.CustomerID,
+			&shipment.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment: %w", err)
+		}
+		shipments = append(shipments, &shipment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list shipments: %w", err)
+	}
+
+	return shipments, nil
+}
+
+// UpdateShipmentStatus moves a shipment from one status to another. The
+// update only applies while the row still has the expected status, so two
+// concurrent transitions cannot both succeed.
+func (r *Repository) UpdateShipmentStatus(ctx context.Context, id, from, to string) error {
+	entry, err := newStatusEntry(ctx, id, from, to)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE shipments SET status = $1 WHERE id = $2 AND status = $3`
+
+	err = pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, query, to, id, from)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrStatusChanged
+		}
+		return audit.AppendPostgres(ctx, tx, entry)
+	})
+	if err != nil && !errors.Is(err, ErrStatusChanged) {
+		return fmt.Errorf("failed to update shipment status: %w", err)
+	}
+	return err
+}
+
+// newCreatedEntry returns the audit entry for the creation of s.
+func newCreatedEntry(ctx context.Context, s *Shipment) (*audit.Entry, error) {
+	return audit.NewEntry(ctx, audit.ActionCreate, audit.EntityShipment, s.ID, nil, s)
+}
+
+// newStatusEntry returns the audit entry for a status transition.
+func newStatusEntry(ctx context.Context, id, from, to string) (*audit.Entry, error) {
+	return audit.NewEntry(ctx, audit.ActionUpdate, audit.EntityShipment, id,
+		map[string]string{"status": from},
+		map[string]string{"status": to},
+	)
+}
+
+func (r *Repository) ListAudit(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error) {
+	return audit.ListPostgres(ctx, r.db, filter)
+}
+
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.Ping(ctx)
+}
@@ -0,0 +1,235 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"testovoe/internal/audit"
+)
+
+// SQLiteRepository stores shipments and customer references in a SQLite
+// database. It is meant for local development; see database.OpenSQLite.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+func (r *SQLiteRepository) CreateShipment(ctx context.Context, shipment *Shipment) error {
+	if shipment.ID == "" {
+		shipment.ID = uuid.New().String()
+	}
+	if shipment.Status == "" {
+		shipment.Status = StatusCreated
+	}
+	if shipment.CreatedAt.IsZero() {
+		shipment.CreatedAt = time.Now().UTC()
+	}
+
+	entry, err := newCreatedEntry(ctx, shipment)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to insert shipment: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO shipments (id, route, price, status, customer_id, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6)`
+
+	_, err = tx.ExecContext(ctx, query,
+		shipment.ID,
+		shipment.Route,
+		shipment.Price,
+		shipment.Status,
+		shipment.CustomerID,
+		shipment.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert shipment: %w", err)
+	}
+
+	if err := audit.AppendSQLite(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert shipment: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) GetShipment(ctx context.Context, id string) (*Shipment, error) {
+	var shipment Shipment
+	query := `SELECT id, route, price, status, customer_id, created_at
+		FROM shipments WHERE id = ?1`
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&shipment.ID,
+		&shipment.Route,
+		&shipment.Price,
+		&shipment.Status,
+		&shipment.CustomerID,
+		&shipment.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query shipment: %w", err)
+	}
+
+	return &shipment, nil
+}
+
+func (r *SQLiteRepository) ListShipments(ctx context.Context, filter ListFilter) ([]*Shipment, error) {
+	query := `SELECT id, route, price, status, customer_id, created_at
+		FROM shipments
+		WHERE (?1 = '' OR customer_id = ?1) AND (?2 = '' OR status = ?2)
+		ORDER BY created_at DESC, id
+		LIMIT ?3 OFFSET ?4`
+
+	rows, err := r.db.QueryContext(ctx, query, filter.CustomerID, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shipments: %w", err)
+	}
+	defer rows.Close()
+
+	var shipments []*Shipment
+	for rows.Next() {
+		var shipment Shipment
+		if err := rows.Scan(
+			&shipment.ID,
+			&shipment.Route,
+			&shipment.Price,
+			&shipment.Status,
+			&shipment.CustomerID,
+			&shipment.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment: %w", err)
+		}
+		shipments = append(shipments, &shipment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list shipments: %w", err)
+	}
+
+	return shipments, nil
+}
+
+func (r *SQLiteRepository) UpdateShipmentStatus(ctx context.Context, id, from, to string) error {
+	entry, err := newStatusEntry(ctx, id, from, to)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update shipment status: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE shipments SET status = ?1 WHERE id = ?2 AND status = ?3`
+
+	res, err := tx.ExecContext(ctx, query, to, id, from)
+	if err != nil {
+		return fmt.Errorf("failed to update shipment status: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrStatusChanged
+	}
+
+	if err := audit.AppendSQLite(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update shipment status: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) ListAudit(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error) {
+	return audit.ListSQLite(ctx, r.db, filter)
+}
+
+const upsertCustomerRefSQLite = `INSERT INTO customer_refs (id, idn, created_at) VALUES (?1, ?2, ?3)
+	ON CONFLICT (id) DO UPDATE SET idn = excluded.idn, created_at = excluded.created_at, synced_at = CURRENT_TIMESTAMP`
+
+func (r *SQLiteRepository) GetCustomerRefByIDN(ctx context.Context, idn string) (*CustomerRef, error) {
+	var ref CustomerRef
+	query := `SELECT id, idn, created_at FROM customer_refs WHERE idn = ?1`
+	err := r.db.QueryRowContext(ctx, query, idn).Scan(
+		&ref.ID,
+		&ref.IDN,
+		&ref.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCustomerNotFound
+		}
+		return nil, fmt.Errorf("failed to query customer reference: %w", err)
+	}
+
+	return &ref, nil
+}
+
+func (r *SQLiteRepository) SaveCustomerRef(ctx context.Context, ref *CustomerRef) error {
+	if _, err := r.db.ExecContext(ctx, upsertCustomerRefSQLite, ref.ID, ref.IDN, ref.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to save customer reference: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) CustomerSyncPosition(ctx context.Context) (int64, error) {
+	var sequence int64
+	err := r.db.QueryRowContext(ctx, `SELECT last_sequence FROM customer_sync WHERE id = 1`).Scan(&sequence)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read customer sync position: %w", err)
+	}
+	return sequence, nil
+}
+
+func (r *SQLiteRepository) ApplyCustomerRefs(ctx context.Context, refs []*CustomerRef, sequence int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to apply customer references: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, ref := range refs {
+		if _, err := tx.ExecContext(ctx, upsertCustomerRefSQLite, ref.ID, ref.IDN, ref.CreatedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to apply customer references: %w", err)
+		}
+	}
+
+	query := `UPDATE customer_sync SET last_sequence = ?1 WHERE id = 1 AND last_sequence < ?1`
+	if _, err := tx.ExecContext(ctx, query, sequence); err != nil {
+		return fmt.Errorf("failed to apply customer references: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to apply customer references: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
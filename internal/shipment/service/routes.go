@@ -0,0 +1,56 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxRouteLabels caps the distinct routes recorded as a metric
+	// attribute; later routes are counted as otherRoute.
+	maxRouteLabels = 100
+	otherRoute     = "other"
+)
+
+// routeRegex is the ORIGIN-DEST form of a normalised route. City names may
+// contain spaces and any letters.
+var routeRegex = regexp.MustCompile(`^\p{Lu}+( \p{Lu}+)*-\p{Lu}+( \p{Lu}+)*$`)
+
+// routeLabels turns the free-text route of a request into a metric
+// attribute with a bounded number of values.
+type routeLabels struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func newRouteLabels() *routeLabels {
+	return &routeLabels{seen: make(map[string]struct{})}
+}
+
+// label returns route in upper case as ORIGIN-DEST, accepting an arrow
+// for the dash and spaces around it, or otherRoute when it has another form
+// or the cap of distinct routes is reached.
+func (l *routeLabels) label(route string) string {
+	route = strings.ReplaceAll(strings.ToUpper(route), "→", "-")
+	origin, dest, ok := strings.Cut(route, "-")
+	if !ok {
+		return otherRoute
+	}
+	route = strings.Join(strings.Fields(origin), " ") + "-" + strings.Join(strings.Fields(dest), " ")
+	if len(route) > 64 || !routeRegex.MatchString(route) {
+		return otherRoute
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.seen[route]; ok {
+		return route
+	}
+	if len(l.seen) >= maxRouteLabels {
+		return otherRoute
+	}
+	l.seen[route] = struct{}{}
+	return route
+}
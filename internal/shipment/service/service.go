@@ -2,24 +2,93 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 
-	"testovoe/internal/shipment/grpc"
+	pb "testovoe/api/proto"
+	"testovoe/internal/audit"
+	"testovoe/internal/auth"
+	"testovoe/internal/redact"
+	"testovoe/internal/shipment/customersync"
 	"testovoe/internal/shipment/repo"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+var (
+	ErrInvalidRequest    = errors.New("invalid request")
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+// transitions lists the statuses a shipment may move to from each status.
+// DELIVERED and CANCELLED are terminal.
+var transitions = map[string][]string{
+	repo.StatusCreated:   {repo.StatusInTransit, repo.StatusCancelled},
+	repo.StatusInTransit: {repo.StatusDelivered, repo.StatusCancelled},
+}
+
+// Repository stores shipments and the local customer references.
+// repo.Repository (Postgres) and repo.MemoryRepository implement it.
+type Repository interface {
+	CreateShipment(ctx context.Context, shipment *repo.Shipment) error
+	GetShipment(ctx context.Context, id string) (*repo.Shipment, error)
+	ListShipments(ctx context.Context, filter repo.ListFilter) ([]*repo.Shipment, error)
+	UpdateShipmentStatus(ctx context.Context, id, from, to string) error
+	GetCustomerRefByIDN(ctx context.Context, idn string) (*repo.CustomerRef, error)
+	SaveCustomerRef(ctx context.Context, ref *repo.CustomerRef) error
+}
+
+// CustomerClient is the part of customer-service the shipment service
+// calls. grpc.Client and grpc.MemoryClient implement it.
+type CustomerClient interface {
+	UpsertCustomer(ctx context.Context, idn string) (*pb.CustomerResponse, error)
+}
+
 type Service struct {
-	repo         *repo.Repository
-	customerGrpc *grpc.Client
+	repo         Repository
+	customerGrpc CustomerClient
+
+	created     metric.Int64Counter
+	transitions metric.Int64Counter
+	prices      metric.Float64Histogram
+	routes      *routeLabels
 }
 
-func NewService(repo *repo.Repository, customerGrpc *grpc.Client) *Service {
+func NewService(repo Repository, customerGrpc CustomerClient) *Service {
+	meter := otel.Meter("shipment-service")
+
+	// Instrument creation only fails on an invalid name.
+	created, _ := meter.Int64Counter("shipments.created",
+		metric.WithDescription("Shipments created, by route and initial status"),
+		metric.WithUnit("{shipment}"),
+	)
+	transitions, _ := meter.Int64Counter("shipments.status_transitions",
+		metric.WithDescription("Shipment status changes, by new status"),
+		metric.WithUnit("{shipment}"),
+	)
+	prices, _ := meter.Float64Histogram("shipment.price",
+		metric.WithDescription("Price of created shipments"),
+		metric.WithUnit("{price}"),
+		metric.WithExplicitBucketBoundaries(10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 50000),
+	)
+
 	return &Service{
 		repo:         repo,
 		customerGrpc: customerGrpc,
+		created:      created,
+		transitions:  transitions,
+		prices:       prices,
+		routes:       newRouteLabels(),
 	}
 }
 
@@ -31,34 +100,43 @@ type CreateShipmentRequest struct {
 	}
 }
 
+type ListShipmentsRequest struct {
+	CustomerID string
+	Status     string
+	Limit      int
+	Offset     int
+}
+
 func (s *Service) CreateShipment(ctx context.Context, req CreateShipmentRequest) (*repo.Shipment, error) {
 	ctx, span := otel.Tracer("shipment-service").Start(ctx, "CreateShipment")
 	defer span.End()
 
+	ctx = withActor(ctx)
+
 	span.SetAttributes(
 		attribute.String("shipment.route", req.Route),
 		attribute.Float64("shipment.price", req.Price),
-		attribute.String("customer.idn", req.Customer.IDN),
+		attribute.String("customer.idn", redact.IDN(req.Customer.IDN)),
 	)
 
 	if len(req.Customer.IDN) != 12 {
 		span.RecordError(fmt.Errorf("invalid idn length"))
-		return nil, fmt.Errorf("idn must be exactly 12 digits")
+		return nil, fmt.Errorf("%w: idn must be exactly 12 digits", ErrInvalidRequest)
 	}
 
-	customerResp, err := s.customerGrpc.UpsertCustomer(ctx, req.Customer.IDN)
+	customer, err := s.resolveCustomer(ctx, req.Customer.IDN)
 	if err != nil {
 		span.RecordError(err)
-		return nil, fmt.Errorf("failed to upsert customer: %w", err)
+		return nil, err
 	}
 
-	span.SetAttributes(attribute.String("customer.id", customerResp.Id))
+	span.SetAttributes(attribute.String("customer.id", customer.ID))
 
 	shipment := &repo.Shipment{
 		Route:      req.Route,
 		Price:      req.Price,
-		Status:     "CREATED",
-		CustomerID: customerResp.Id,
+		Status:     repo.StatusCreated,
+		CustomerID: customer.ID,
 	}
 
 	if err := s.repo.CreateShipment(ctx, shipment); err != nil {
@@ -66,9 +144,51 @@ func (s *Service) CreateShipment(ctx context.Context, req CreateShipmentRequest)
 		return nil, fmt.Errorf("failed to create shipment: %w", err)
 	}
 
+	s.created.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("shipment.route", s.routes.label(shipment.Route)),
+		attribute.String("shipment.status", shipment.Status),
+	))
+	s.prices.Record(ctx, shipment.Price)
+
 	return shipment, nil
 }
 
+// resolveCustomer finds the customer for idn in the local reference table
+// and only asks customer-service, which owns customers, when it is not
+// there yet. The answer is stored locally so the next shipment for the same
+// customer needs no remote call.
+func (s *Service) resolveCustomer(ctx context.Context, idn string) (*repo.CustomerRef, error) {
+	ref, err := s.repo.GetCustomerRefByIDN(ctx, idn)
+	if err == nil {
+		return ref, nil
+	}
+	if !errors.Is(err, repo.ErrCustomerNotFound) {
+		return nil, fmt.Errorf("failed to look up customer: %w", err)
+	}
+
+	customerResp, err := s.customerGrpc.UpsertCustomer(ctx, idn)
+	if err != nil {
+		// customer-service validates the IDN more strictly than we do;
+		// its rejection is still the caller's fault.
+		if status.Code(err) == codes.InvalidArgument {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRequest, status.Convert(err).Message())
+		}
+		return nil, fmt.Errorf("failed to upsert customer: %w", err)
+	}
+
+	ref, err = customersync.ToCustomerRef(customerResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert customer: %w", err)
+	}
+
+	if err := s.repo.SaveCustomerRef(ctx, ref); err != nil {
+		// The sync worker will store it from the event feed.
+		slog.WarnContext(ctx, "Failed to save customer reference", "customer.id", ref.ID, "error", err)
+	}
+
+	return ref, nil
+}
+
 func (s *Service) GetShipment(ctx context.Context, id string) (*repo.Shipment, error) {
 	ctx, span := otel.Tracer("shipment-service").Start(ctx, "GetShipment")
 	defer span.End()
@@ -83,3 +203,91 @@ func (s *Service) GetShipment(ctx context.Context, id string) (*repo.Shipment, e
 
 	return shipment, nil
 }
+
+func (s *Service) ListShipments(ctx context.Context, req ListShipmentsRequest) ([]*repo.Shipment, error) {
+	ctx, span := otel.Tracer("shipment-service").Start(ctx, "ListShipments")
+	defer span.End()
+
+	if req.Limit < 0 || req.Offset < 0 {
+		span.RecordError(fmt.Errorf("negative pagination"))
+		return nil, fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidRequest)
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultListLimit
+	}
+	if req.Limit > maxListLimit {
+		req.Limit = maxListLimit
+	}
+
+	span.SetAttributes(
+		attribute.String("shipment.customer_id", req.CustomerID),
+		attribute.String("shipment.status", req.Status),
+	)
+
+	shipments, err := s.repo.ListShipments(ctx, repo.ListFilter{
+		CustomerID: req.CustomerID,
+		Status:     req.Status,
+		Limit:      req.Limit,
+		Offset:     req.Offset,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list shipments: %w", err)
+	}
+
+	return shipments, nil
+}
+
+func (s *Service) TransitionShipment(ctx context.Context, id, status string) (*repo.Shipment, error) {
+	ctx, span := otel.Tracer("shipment-service").Start(ctx, "TransitionShipment")
+	defer span.End()
+
+	ctx = withActor(ctx)
+
+	span.SetAttributes(
+		attribute.String("shipment.id", id),
+		attribute.String("shipment.status.to", status),
+	)
+
+	shipment, err := s.repo.GetShipment(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get shipment: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("shipment.status.from", shipment.Status))
+
+	if !canTransition(shipment.Status, status) {
+		err := fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, shipment.Status, status)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if err := s.repo.UpdateShipmentStatus(ctx, id, shipment.Status, status); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to transition shipment: %w", err)
+	}
+
+	shipment.Status = status
+	s.transitions.Add(ctx, 1, metric.WithAttributes(attribute.String("shipment.status", status)))
+	return shipment, nil
+}
+
+// withActor records the authenticated caller, if any, as the actor of the
+// audit entries written in ctx.
+func withActor(ctx context.Context) context.Context {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return audit.WithActor(ctx, p.Subject)
+}
+
+func canTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
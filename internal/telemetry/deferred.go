@@ -0,0 +1,118 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// deferred holds the real exporter once the collector has answered. The
+// providers are built at startup around a deferred exporter, which drops
+// data until then.
+type deferred[E any] struct {
+	exporter atomic.Pointer[E]
+}
+
+func (d *deferred[E]) get() (E, bool) {
+	if e := d.exporter.Load(); e != nil {
+		return *e, true
+	}
+	var zero E
+	return zero, false
+}
+
+// connectLater sets the exporter built by newExporter into d once the
+// collector answers. signal names the exporter in the log.
+func connectLater[E any](c *Collector, d *deferred[E], signal string, newExporter func() (E, error)) {
+	c.whenAvailable(func() {
+		e, err := newExporter()
+		if err != nil {
+			slog.Warn("OTLP export disabled", "signal", signal, "error", err)
+			return
+		}
+		d.exporter.Store(&e)
+	})
+}
+
+type deferredSpanExporter struct {
+	deferred[sdktrace.SpanExporter]
+}
+
+func (d *deferredSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e, ok := d.get(); ok {
+		return e.ExportSpans(ctx, spans)
+	}
+	return nil
+}
+
+func (d *deferredSpanExporter) Shutdown(ctx context.Context) error {
+	if e, ok := d.get(); ok {
+		return e.Shutdown(ctx)
+	}
+	return nil
+}
+
+// deferredMetricExporter reports the SDK default temporality and
+// aggregation, which the OTLP exporter also uses.
+type deferredMetricExporter struct {
+	deferred[sdkmetric.Exporter]
+}
+
+func (d *deferredMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (d *deferredMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (d *deferredMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if e, ok := d.get(); ok {
+		return e.Export(ctx, rm)
+	}
+	return nil
+}
+
+func (d *deferredMetricExporter) ForceFlush(ctx context.Context) error {
+	if e, ok := d.get(); ok {
+		return e.ForceFlush(ctx)
+	}
+	return nil
+}
+
+func (d *deferredMetricExporter) Shutdown(ctx context.Context) error {
+	if e, ok := d.get(); ok {
+		return e.Shutdown(ctx)
+	}
+	return nil
+}
+
+type deferredLogExporter struct {
+	deferred[sdklog.Exporter]
+}
+
+func (d *deferredLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	if e, ok := d.get(); ok {
+		return e.Export(ctx, records)
+	}
+	return nil
+}
+
+func (d *deferredLogExporter) ForceFlush(ctx context.Context) error {
+	if e, ok := d.get(); ok {
+		return e.ForceFlush(ctx)
+	}
+	return nil
+}
+
+func (d *deferredLogExporter) Shutdown(ctx context.Context) error {
+	if e, ok := d.get(); ok {
+		return e.Shutdown(ctx)
+	}
+	return nil
+}
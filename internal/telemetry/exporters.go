@@ -0,0 +1,176 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"testovoe/internal/config"
+)
+
+// NewTraceExporter returns the span exporter selected by
+// cfg.TracesExporter, or nil when spans should not be exported. With "otlp"
+// and a collector that has not answered yet, spans are dropped until it
+// does.
+func NewTraceExporter(ctx context.Context, cfg config.TelemetryConfig, collector *Collector) (sdktrace.SpanExporter, error) {
+	switch cfg.TracesExporter {
+	case config.TracesExporterNone:
+		return nil, nil
+
+	case config.TracesExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+
+	case config.TracesExporterFile:
+		f, err := os.OpenFile(cfg.TracesFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open traces file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exporter, file: f}, nil
+	}
+
+	if collector == nil {
+		return nil, nil
+	}
+	if collector.Available() {
+		return newOTLPTraceExporter(ctx, collector.target)
+	}
+	exporter := &deferredSpanExporter{}
+	connectLater(collector, &exporter.deferred, "traces", func() (sdktrace.SpanExporter, error) {
+		return newOTLPTraceExporter(ctx, collector.target)
+	})
+	return exporter, nil
+}
+
+func newOTLPTraceExporter(ctx context.Context, t *otlpTarget) (sdktrace.SpanExporter, error) {
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(t.endpoint),
+		otlptracegrpc.WithHeaders(t.headers),
+	}
+	if t.creds != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(t.creds))
+	} else {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// fileExporter closes the file once the exporter has flushed into it.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if cerr := e.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// NewMetricExporter returns the OTLP metric exporter, or nil without a
+// collector. Until the collector answers, metrics are dropped.
+func NewMetricExporter(ctx context.Context, collector *Collector) (sdkmetric.Exporter, error) {
+	if collector == nil {
+		return nil, nil
+	}
+	if collector.Available() {
+		return newOTLPMetricExporter(ctx, collector.target)
+	}
+	exporter := &deferredMetricExporter{}
+	connectLater(collector, &exporter.deferred, "metrics", func() (sdkmetric.Exporter, error) {
+		return newOTLPMetricExporter(ctx, collector.target)
+	})
+	return exporter, nil
+}
+
+func newOTLPMetricExporter(ctx context.Context, t *otlpTarget) (sdkmetric.Exporter, error) {
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(t.endpoint),
+		otlpmetricgrpc.WithHeaders(t.headers),
+	}
+	if t.creds != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(t.creds))
+	} else {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+
+	exporter, err := otlpmetricgrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// NewLogExporter returns the OTLP log exporter, or nil without a
+// collector. Until the collector answers, records are dropped.
+func NewLogExporter(ctx context.Context, collector *Collector) (sdklog.Exporter, error) {
+	if collector == nil {
+		return nil, nil
+	}
+	if collector.Available() {
+		return newOTLPLogExporter(ctx, collector.target)
+	}
+	exporter := &deferredLogExporter{}
+	connectLater(collector, &exporter.deferred, "logs", func() (sdklog.Exporter, error) {
+		return newOTLPLogExporter(ctx, collector.target)
+	})
+	return exporter, nil
+}
+
+func newOTLPLogExporter(ctx context.Context, t *otlpTarget) (sdklog.Exporter, error) {
+	opts := []otlploggrpc.Option{
+		otlploggrpc.WithEndpoint(t.endpoint),
+		otlploggrpc.WithHeaders(t.headers),
+	}
+	if t.creds != nil {
+		opts = append(opts, otlploggrpc.WithTLSCredentials(t.creds))
+	} else {
+		opts = append(opts, otlploggrpc.WithInsecure())
+	}
+
+	exporter, err := otlploggrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// NewSampler returns the sampler named by cfg.TracesSampler.
+func NewSampler(cfg config.TelemetryConfig) (sdktrace.Sampler, error) {
+	ratio := sdktrace.TraceIDRatioBased(cfg.TracesSamplerArg)
+
+	switch cfg.TracesSampler {
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		return ratio, nil
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		return sdktrace.ParentBased(ratio), nil
+	}
+	return nil, fmt.Errorf("unknown sampler %q", cfg.TracesSampler)
+}
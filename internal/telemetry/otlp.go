@@ -0,0 +1,200 @@
+// Package telemetry sets up the OpenTelemetry providers, exporters and
+// samplers configured by config.TelemetryConfig.
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+
+	"testovoe/internal/config"
+)
+
+const (
+	// collectorProbeTimeout bounds a single connection attempt, so an
+	// unreachable collector delays startup by at most this much.
+	collectorProbeTimeout = time.Second
+	// collectorRetryInterval is how often a collector that did not answer
+	// at startup is probed again; docker-compose may start it after us.
+	collectorRetryInterval = 5 * time.Second
+)
+
+// otlpTarget is the collector address and transport security shared by
+// the trace, metric and log exporters.
+type otlpTarget struct {
+	endpoint string
+	// creds is nil for a plaintext connection.
+	creds   credentials.TransportCredentials
+	headers map[string]string
+}
+
+func newOTLPTarget(cfg config.TelemetryConfig) (*otlpTarget, error) {
+	endpoint := cfg.Endpoint
+	insecure := cfg.Insecure
+	switch {
+	case strings.HasPrefix(endpoint, "https://"):
+		endpoint, insecure = strings.TrimPrefix(endpoint, "https://"), false
+	case strings.HasPrefix(endpoint, "http://"):
+		endpoint, insecure = strings.TrimPrefix(endpoint, "http://"), true
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	t := &otlpTarget{endpoint: endpoint, headers: cfg.Headers}
+	if insecure {
+		return t, nil
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Certificate != "" {
+		pem, err := os.ReadFile(cfg.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OTLP certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("failed to parse OTLP certificate")
+		}
+		tlsCfg.RootCAs = pool
+	}
+	t.creds = credentials.NewTLS(tlsCfg)
+	return t, nil
+}
+
+// reachable reports whether a TCP connection to the collector can be made
+// within collectorProbeTimeout.
+func (t *otlpTarget) reachable(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, collectorProbeTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.endpoint)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Collector resolves the OTLP settings once and tracks whether the
+// collector answers. When it does not answer at startup, each signal starts
+// with an exporter that drops its data, and the collector is probed again in
+// the background until the real exporters can be connected.
+type Collector struct {
+	target *otlpTarget
+
+	mu        sync.Mutex
+	available bool
+	closed    bool
+	// pending connects the real exporters once the collector answers.
+	pending []func()
+	stop    chan struct{}
+}
+
+// NewCollector checks the OTLP settings and probes the collector. An
+// unreachable collector is logged, not returned as an error.
+func NewCollector(ctx context.Context, cfg config.TelemetryConfig) (*Collector, error) {
+	target, err := newOTLPTarget(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Collector{target: target, stop: make(chan struct{})}
+	if !usesOTLP(cfg) {
+		return c, nil
+	}
+
+	c.available = target.reachable(ctx)
+	if !c.available {
+		slog.Warn("OTLP collector unreachable, OTLP export starts once it answers",
+			"endpoint", target.endpoint, "retry_interval", collectorRetryInterval.String())
+		go c.retry(ctx)
+	}
+	return c, nil
+}
+
+func usesOTLP(cfg config.TelemetryConfig) bool {
+	return cfg.TracesExporter == config.TracesExporterOTLP ||
+		cfg.OTLPMetricsEnabled() ||
+		cfg.LogsExporter == config.LogsExporterOTLP
+}
+
+// Available reports whether the collector has answered.
+func (c *Collector) Available() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.available
+}
+
+// whenAvailable calls connect now if the collector has answered, and
+// otherwise once it does. connect is never called after Close.
+func (c *Collector) whenAvailable(connect func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.closed:
+	case c.available:
+		connect()
+	default:
+		c.pending = append(c.pending, connect)
+	}
+}
+
+// retry probes the collector every collectorRetryInterval until it answers
+// or the collector is closed, then connects the pending exporters.
+func (c *Collector) retry(ctx context.Context) {
+	ticker := time.NewTicker(collectorRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		if !c.target.reachable(ctx) {
+			continue
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.closed {
+			return
+		}
+		c.available = true
+		for _, connect := range c.pending {
+			connect()
+		}
+		c.pending = nil
+		slog.Info("OTLP collector reachable, OTLP export started", "endpoint", c.target.endpoint)
+		return
+	}
+}
+
+// Close stops the background probe; exporters not yet connected stay
+// no-ops.
+func (c *Collector) Close() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.stop)
+	}
+}
@@ -0,0 +1,53 @@
+package telemetry
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"testovoe/internal/config"
+)
+
+// version is the service version; release builds set it with
+// -ldflags "-X testovoe/internal/telemetry.version=v1.2.3".
+var version = ""
+
+// instanceID tells apart replicas of the same service. It is new for every
+// process start.
+var instanceID = uuid.NewString()
+
+// Version returns the build version: the -ldflags value, else the VCS
+// revision recorded by the Go toolchain, else "dev".
+func Version() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+				return s.Value[:12]
+			}
+		}
+	}
+	return "dev"
+}
+
+// NewResource describes this process to the telemetry backends. Attributes
+// from OTEL_RESOURCE_ATTRIBUTES are included; the configured values win.
+// On error the returned resource holds whatever could be detected.
+func NewResource(ctx context.Context, cfg config.TelemetryConfig) (*resource.Resource, error) {
+	return resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(Version()),
+			semconv.ServiceInstanceID(instanceID),
+			semconv.DeploymentEnvironment(cfg.Environment),
+		),
+	)
+}
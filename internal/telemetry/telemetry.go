@@ -0,0 +1,186 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"testovoe/internal/config"
+	"testovoe/internal/logging"
+	"testovoe/internal/metrics"
+	"testovoe/internal/redact"
+)
+
+// Providers holds the tracer, meter and logger providers installed by
+// Setup.
+type Providers struct {
+	// MetricsHandler serves /metrics; nil unless Prometheus export is on.
+	MetricsHandler http.Handler
+
+	tracer *sdktrace.TracerProvider
+	meter  *sdkmetric.MeterProvider
+	// logger is nil when logs are not exported.
+	logger *sdklog.LoggerProvider
+	// collector is nil when the OTLP settings are invalid.
+	collector *Collector
+
+	cfg config.TelemetryConfig
+}
+
+// Setup applies the redaction policy and log format, then installs the
+// global tracer and meter providers and the propagator. Exporters that
+// cannot be created are logged and skipped, so telemetry never stops the
+// service; only an invalid configuration is returned as an error.
+func Setup(ctx context.Context, cfg *config.Config) (*Providers, error) {
+	tc := cfg.Telemetry
+
+	// Before anything logs or traces a customer IDN.
+	if err := redact.Configure(tc.PIIRedaction, tc.PIIHashKey); err != nil {
+		return nil, err
+	}
+	if err := logging.SetLevel(cfg.Runtime.LogLevel); err != nil {
+		return nil, err
+	}
+
+	collector, err := NewCollector(ctx, tc)
+	if err != nil {
+		slog.Warn("OTLP export disabled", "error", err)
+	}
+
+	res, err := NewResource(ctx, tc)
+	if err != nil {
+		slog.Warn("Incomplete telemetry resource", "error", err)
+	}
+
+	p := &Providers{collector: collector, cfg: tc}
+
+	p.logger = newLoggerProvider(ctx, tc, collector, res)
+	var extra []slog.Handler
+	if p.logger != nil {
+		extra = append(extra, otelslog.NewHandler(tc.ServiceName, otelslog.WithLoggerProvider(p.logger)))
+	}
+	if err := logging.Configure(cfg.Log.Format, extra...); err != nil {
+		return nil, err
+	}
+
+	if p.tracer, err = newTracerProvider(ctx, tc, collector, res); err != nil {
+		return nil, err
+	}
+	p.meter, p.MetricsHandler = newMeterProvider(ctx, tc, collector, res)
+
+	otel.SetTracerProvider(p.tracer)
+	otel.SetMeterProvider(p.meter)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return p, nil
+}
+
+// Shutdown flushes and stops all providers within the configured
+// shutdown timeout. Logs go last so records about the shutdown itself are
+// still exported; errors are still written to the local log afterwards.
+func (p *Providers) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.ShutdownTimeout)
+	defer cancel()
+
+	p.collector.Close()
+
+	var errs []error
+	if err := p.tracer.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("tracer provider: %w", err))
+	}
+	if err := p.meter.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("meter provider: %w", err))
+	}
+	if p.logger != nil {
+		if err := p.logger.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("logger provider: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func newTracerProvider(ctx context.Context, cfg config.TelemetryConfig, collector *Collector, res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	sampler, err := NewSampler(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(res),
+	}
+
+	exporter, err := NewTraceExporter(ctx, cfg, collector)
+	if err != nil {
+		slog.Warn("Trace export disabled", "error", err)
+	}
+	if exporter != nil {
+		// Scrubs IDNs that reached span attributes without redact.IDN.
+		opts = append(opts, sdktrace.WithSpanProcessor(redact.NewSpanProcessor(sdktrace.NewBatchSpanProcessor(exporter))))
+	}
+
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// newMeterProvider returns the provider with the readers selected by
+// cfg.MetricsExporter and, when Prometheus export is on, the handler for
+// /metrics. With no readers the provider drops every measurement.
+func newMeterProvider(ctx context.Context, cfg config.TelemetryConfig, collector *Collector, res *resource.Resource) (*sdkmetric.MeterProvider, http.Handler) {
+	opts := []sdkmetric.Option{sdkmetric.WithResource(res)}
+	var handler http.Handler
+
+	if cfg.OTLPMetricsEnabled() {
+		exporter, err := NewMetricExporter(ctx, collector)
+		if err != nil {
+			slog.Warn("OTLP metric export disabled", "error", err)
+		} else if exporter != nil {
+			opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)))
+		}
+	}
+
+	if cfg.PrometheusEnabled() {
+		reader, h, err := metrics.NewPrometheusReader()
+		if err != nil {
+			slog.Warn("Prometheus metric export disabled", "error", err)
+		} else {
+			opts = append(opts, sdkmetric.WithReader(reader))
+			handler = h
+		}
+	}
+
+	return sdkmetric.NewMeterProvider(opts...), handler
+}
+
+// newLoggerProvider returns the provider exporting logs over OTLP, or nil
+// when logs stay local.
+func newLoggerProvider(ctx context.Context, cfg config.TelemetryConfig, collector *Collector, res *resource.Resource) *sdklog.LoggerProvider {
+	if cfg.LogsExporter != config.LogsExporterOTLP {
+		return nil
+	}
+
+	exporter, err := NewLogExporter(ctx, collector)
+	if err != nil {
+		slog.Warn("OTLP log export disabled", "error", err)
+	}
+	if exporter == nil {
+		return nil
+	}
+
+	return sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+		sdklog.WithResource(res),
+	)
+}
@@ -0,0 +1,18 @@
+-- customer_events is the feed other services read to keep a local copy of
+-- customers (see ListCustomerEvents). Rows are never updated or deleted.
+CREATE TABLE IF NOT EXISTS customer_events (
+    sequence BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    customer_id UUID NOT NULL,
+    idn TEXT NOT NULL,
+    customer_created_at TIMESTAMP NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Customers created before the feed existed get a creation event so
+-- consumers starting from sequence 0 see every customer.
+INSERT INTO customer_events (type, customer_id, idn, customer_created_at, occurred_at)
+SELECT 'CUSTOMER_CREATED', id, idn, created_at, created_at
+FROM customers
+WHERE NOT EXISTS (SELECT 1 FROM customer_events)
+ORDER BY created_at, id;
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS customers (
+    id TEXT PRIMARY KEY,
+    idn TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
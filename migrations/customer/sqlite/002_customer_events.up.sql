@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS customer_events (
+    sequence INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    customer_id TEXT NOT NULL,
+    idn TEXT NOT NULL,
+    customer_created_at TIMESTAMP NOT NULL,
+    occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO customer_events (type, customer_id, idn, customer_created_at, occurred_at)
+SELECT 'CUSTOMER_CREATED', id, idn, created_at, created_at
+FROM customers
+WHERE NOT EXISTS (SELECT 1 FROM customer_events)
+ORDER BY created_at, id;
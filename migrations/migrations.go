@@ -0,0 +1,33 @@
+// Package migrations embeds the SQL migrations of both services, one
+// directory per service and SQL dialect (postgres, sqlite). Files are named
+// <version>_<name>.up.sql and <version>_<name>.down.sql; both dialects of a
+// service use the same versions.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+//go:embed customer/postgres/*.sql customer/sqlite/*.sql shipment/postgres/*.sql shipment/sqlite/*.sql
+var files embed.FS
+
+// Customer returns the migrations owned by customer-service for dialect.
+func Customer(dialect string) (fs.FS, error) {
+	return sub("customer", dialect)
+}
+
+// Shipment returns the migrations owned by shipment-service for dialect.
+func Shipment(dialect string) (fs.FS, error) {
+	return sub("shipment", dialect)
+}
+
+func sub(service, dialect string) (fs.FS, error) {
+	dir := path.Join(service, dialect)
+	if _, err := fs.Stat(files, dir); err != nil {
+		return nil, fmt.Errorf("no %s migrations for dialect %q", service, dialect)
+	}
+	return fs.Sub(files, dir)
+}
@@ -0,0 +1,2 @@
+-- Not dropped: in a database shared with customer-service this is its table.
+SELECT 1;
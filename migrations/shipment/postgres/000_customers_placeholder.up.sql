@@ -0,0 +1,7 @@
+-- 001 shipped while shipments lived in the customer database and references
+-- customers(id). A database of its own has no such table, so an empty one is
+-- created for 001 to apply unchanged; 002 then drops the foreign key. Where
+-- customer-service's table exists this does nothing.
+CREATE TABLE IF NOT EXISTS customers (
+    id UUID PRIMARY KEY
+);
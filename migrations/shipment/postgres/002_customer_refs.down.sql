@@ -0,0 +1,3 @@
+-- The foreign key is not restored: customers are no longer in this database.
+DROP TABLE IF EXISTS customer_sync;
+DROP TABLE IF EXISTS customer_refs;
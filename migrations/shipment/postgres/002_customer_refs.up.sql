@@ -0,0 +1,21 @@
+-- Databases created before the split have shipments in the customer
+-- database with a foreign key to customers. Shipments now live in their own
+-- database, so the key is dropped where it still exists.
+ALTER TABLE shipments DROP CONSTRAINT IF EXISTS shipments_customer_id_fkey;
+
+-- customer_refs is the local, read-only copy of the customers that
+-- shipments refer to. It is filled from the customer-service event feed.
+CREATE TABLE IF NOT EXISTS customer_refs (
+    id UUID PRIMARY KEY,
+    idn TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    synced_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- customer_sync holds a single row: the last applied event sequence.
+CREATE TABLE IF NOT EXISTS customer_sync (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_sequence BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO customer_sync (id, last_sequence) VALUES (TRUE, 0) ON CONFLICT (id) DO NOTHING;
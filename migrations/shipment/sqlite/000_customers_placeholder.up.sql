@@ -0,0 +1,3 @@
+-- Postgres needs a placeholder customers table for 001; the SQLite schema
+-- never referenced customers. Kept so both dialects use the same versions.
+SELECT 1;
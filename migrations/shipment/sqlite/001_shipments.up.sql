@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS shipments (
+    id TEXT PRIMARY KEY,
+    route TEXT NOT NULL,
+    price REAL NOT NULL,
+    status TEXT NOT NULL DEFAULT 'CREATED',
+    customer_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipments_customer_id ON shipments(customer_id);
+CREATE INDEX IF NOT EXISTS idx_shipments_status ON shipments(status);
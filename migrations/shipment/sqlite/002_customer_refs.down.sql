@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_sync;
+DROP TABLE IF EXISTS customer_refs;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS customer_refs (
+    id TEXT PRIMARY KEY,
+    idn TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    synced_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS customer_sync (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_sequence INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO customer_sync (id, last_sequence) VALUES (1, 0) ON CONFLICT (id) DO NOTHING;